@@ -0,0 +1,420 @@
+package testy
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// TestingT is the subset of testing.TB used by the Response assertions, satisfied by *testing.T.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// FatalT is the subset of testing.TB used by WithT, satisfied by *testing.T.
+type FatalT interface {
+	Helper()
+	Fatalf(format string, args ...interface{})
+}
+
+// AssertStatus method fails the test unless the response status code matches.
+//
+// 		client.Get("/hello").
+// 			AssertStatus(t, http.StatusOK).
+// 			AssertBodyContains(t, "hello")
+func (r *Response) AssertStatus(t TestingT, code int) *Response {
+	t.Helper()
+	if r.StatusCode != code {
+		t.Errorf("expected status code %d, got %d", code, r.StatusCode)
+	}
+	return r
+}
+
+// AssertSuccess method fails the test unless the response status code is 2xx.
+func (r *Response) AssertSuccess(t TestingT) *Response {
+	t.Helper()
+	if !r.IsSuccess() {
+		t.Errorf("expected success status code, got %d", r.StatusCode)
+	}
+	return r
+}
+
+// AssertRedirect method fails the test unless the response is a 3xx redirect to the location.
+// Relative locations are resolved against the request URL, so either form matches.
+//
+// 		client.Get("/old").AssertRedirect(t, "/new")
+func (r *Response) AssertRedirect(t TestingT, expectedLocation string) *Response {
+	t.Helper()
+	if r.StatusCode < 300 || r.StatusCode >= 400 {
+		t.Errorf("expected redirect status code, got %d", r.StatusCode)
+		return r
+	}
+	location := r.GetHeader("Location")
+	if resolveLocation(r.Request, location) != resolveLocation(r.Request, expectedLocation) {
+		t.Errorf("expected redirect to %q, got %q", expectedLocation, location)
+	}
+	return r
+}
+
+func resolveLocation(request *http.Request, location string) string {
+	u, err := url.Parse(location)
+	if err != nil || request == nil {
+		return location
+	}
+	return request.URL.ResolveReference(u).String()
+}
+
+// AssertHeader method fails the test unless the first value of the response header matches.
+//
+// 		client.SetHeader("Origin", "https://example.org").Options("/data").
+// 			AssertHeader(t, "Access-Control-Allow-Origin", "https://example.org")
+func (r *Response) AssertHeader(t TestingT, key, value string) *Response {
+	t.Helper()
+	if actual := r.GetHeader(key); actual != value {
+		t.Errorf("expected header %s to be %q, got %q", key, value, actual)
+	}
+	return r
+}
+
+// AssertBodyEquals method fails the test unless the response body is exactly expected.
+// The failure shows both bodies, truncated, and the offset of the first difference.
+//
+// 		client.Get("/hello").AssertBodyEquals(t, "hello, world!")
+func (r *Response) AssertBodyEquals(t TestingT, expected string) *Response {
+	t.Helper()
+	return r.AssertBodyBytesEqual(t, []byte(expected))
+}
+
+// AssertBodyBytesEqual method fails the test unless the response body is exactly expected,
+// the same way as AssertBodyEquals.
+func (r *Response) AssertBodyBytesEqual(t TestingT, expected []byte) *Response {
+	t.Helper()
+	if bytes.Equal(r.Body, expected) {
+		return r
+	}
+	at := 0
+	for at < len(expected) && at < len(r.Body) && expected[at] == r.Body[at] {
+		at++
+	}
+	t.Errorf("expected body to equal %s, got %s (first difference at byte %d)",
+		truncateBody(expected), truncateBody(r.Body), at)
+	return r
+}
+
+// assertBodyLimit is the number of body bytes shown by a failed assertion.
+const assertBodyLimit = 256
+
+func truncateBody(body []byte) string {
+	if len(body) > assertBodyLimit {
+		return fmt.Sprintf("%q... (%d more bytes)", body[:assertBodyLimit], len(body)-assertBodyLimit)
+	}
+	return fmt.Sprintf("%q", body)
+}
+
+// AssertContentType method fails the test unless the media type of the response Content-Type
+// matches, ignoring parameters such as charset.
+//
+// 		client.Get("/user").AssertContentType(t, "application/json")
+func (r *Response) AssertContentType(t TestingT, mediaType string) *Response {
+	t.Helper()
+	contentType := r.GetHeader("Content-Type")
+	actual, _, err := mime.ParseMediaType(contentType)
+	if err != nil || actual != mediaType {
+		t.Errorf("expected Content-Type %s, got %q", mediaType, contentType)
+	}
+	return r
+}
+
+// AssertBodyContains method fails the test unless the response body contains substr.
+func (r *Response) AssertBodyContains(t TestingT, substr string) *Response {
+	t.Helper()
+	if !strings.Contains(r.String(), substr) {
+		t.Errorf("expected body to contain %q, got %q", substr, r.String())
+	}
+	return r
+}
+
+// AssertEmpty method fails the test unless the response body is empty.
+//
+// 		client.Delete("/users/1").
+// 			AssertStatus(t, http.StatusNoContent).
+// 			AssertEmpty(t)
+func (r *Response) AssertEmpty(t TestingT) *Response {
+	t.Helper()
+	if !r.IsEmpty() {
+		t.Errorf("expected empty body, got %q", r.String())
+	}
+	return r
+}
+
+// AssertJSON method fails the test unless the response body is valid JSON.
+func (r *Response) AssertJSON(t TestingT) *Response {
+	t.Helper()
+	if !r.IsJSON() {
+		t.Errorf("expected JSON body, got %q", r.String())
+	}
+	return r
+}
+
+// JSONInto method unmarshals the JSON body into v and runs the validate callback on it,
+// failing the test on either error. Validate may be nil.
+//
+// 		var user User
+// 		response.JSONInto(t, &user, func(v interface{}) error {
+// 			if v.(*User).Name == "" {
+// 				return errors.New("name is required")
+// 			}
+// 			return nil
+// 		})
+func (r *Response) JSONInto(t TestingT, v interface{}, validate func(interface{}) error) *Response {
+	t.Helper()
+	if err := r.JSON(v); err != nil {
+		t.Errorf("decoding JSON body: %v", err)
+		return r
+	}
+	if validate != nil {
+		if err := validate(v); err != nil {
+			t.Errorf("validating JSON body: %v", err)
+		}
+	}
+	return r
+}
+
+// AssertJSONPath method fails the test unless the value at the dotted path of the JSON body
+// equals expected. Array elements are addressed by index. Expected is compared as JSON,
+// so e.g. int and float64 numbers are equal.
+//
+// 		response.AssertJSONPath(t, "data.items.0.name", "bob")
+func (r *Response) AssertJSONPath(t TestingT, path string, expected interface{}) *Response {
+	t.Helper()
+	var body interface{}
+	if err := json.Unmarshal(r.Body, &body); err != nil {
+		t.Errorf("expected JSON body: %v", err)
+		return r
+	}
+	actual, err := lookupJSONPath(body, path)
+	if err != nil {
+		t.Errorf("%v", err)
+		return r
+	}
+	if !jsonEqual(actual, expected) {
+		t.Errorf("expected %q to be %#v, got %#v", path, expected, actual)
+	}
+	return r
+}
+
+func lookupJSONPath(value interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return value, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if value, ok = v[key]; !ok {
+				return nil, fmt.Errorf("path %q not found: no key %q", path, key)
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("path %q not found: no index %q in array of length %d", path, key, len(v))
+			}
+			value = v[i]
+		default:
+			return nil, fmt.Errorf("path %q not found: %q is not an object or array", path, key)
+		}
+	}
+	return value, nil
+}
+
+// jsonEqual compares decoded JSON value against expected, which is normalized through JSON.
+func jsonEqual(actual, expected interface{}) bool {
+	data, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(actual, normalized)
+}
+
+// AssertJSONEquals method fails the test unless the response body is JSON equal to expected,
+// ignoring formatting and key order. The failure lists the paths of the differences.
+//
+// 		client.Get("/user").AssertJSONEquals(t, `{"age": 42, "name": "bob"}`)
+func (r *Response) AssertJSONEquals(t TestingT, expected string) *Response {
+	t.Helper()
+	var want, got interface{}
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Errorf("expected value is not JSON: %v", err)
+		return r
+	}
+	if err := json.Unmarshal(r.Body, &got); err != nil {
+		t.Errorf("expected JSON body, got %q", r.String())
+		return r
+	}
+	if diff := jsonDiff("", want, got); len(diff) > 0 {
+		t.Errorf("expected JSON body to equal %s\n%s", expected, strings.Join(diff, "\n"))
+	}
+	return r
+}
+
+// jsonDiff returns the differences between the decoded JSON values, one per line, by path.
+func jsonDiff(path string, expected, actual interface{}) []string {
+	at := path
+	if at == "" {
+		at = "$"
+	}
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(e)+len(a))
+		for k := range e {
+			keys = append(keys, k)
+		}
+		for k := range a {
+			if _, ok := e[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		var diff []string
+		for _, k := range keys {
+			ev, inExpected := e[k]
+			av, inActual := a[k]
+			switch {
+			case !inActual:
+				diff = append(diff, fmt.Sprintf("%s: missing, expected %s", join(k), jsonString(ev)))
+			case !inExpected:
+				diff = append(diff, fmt.Sprintf("%s: unexpected %s", join(k), jsonString(av)))
+			default:
+				diff = append(diff, jsonDiff(join(k), ev, av)...)
+			}
+		}
+		return diff
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			break
+		}
+		if len(e) != len(a) {
+			return []string{fmt.Sprintf("%s: expected array of length %d, got %d", at, len(e), len(a))}
+		}
+		var diff []string
+		for i := range e {
+			diff = append(diff, jsonDiff(join(strconv.Itoa(i)), e[i], a[i])...)
+		}
+		return diff
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", at, jsonString(expected), jsonString(actual))}
+	}
+	return nil
+}
+
+func jsonString(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// AssertJSONSchema method fails the test unless the response body is valid against
+// the JSON Schema document, reporting each validation error, e.g. for contract tests.
+//
+// 		client.Get("/user").AssertJSONSchema(t, `{
+// 			"type": "object",
+// 			"required": ["name"],
+// 			"properties": {"name": {"type": "string"}}
+// 		}`)
+func (r *Response) AssertJSONSchema(t TestingT, schema string) *Response {
+	t.Helper()
+	result, err := gojsonschema.Validate(gojsonschema.NewStringLoader(schema), gojsonschema.NewBytesLoader(r.Body))
+	if err != nil {
+		t.Errorf("validating JSON schema: %v", err)
+		return r
+	}
+	for _, e := range result.Errors() {
+		t.Errorf("JSON schema: %s", e)
+	}
+	return r
+}
+
+// AssertMatchesGolden method fails the test unless the response body matches the golden file.
+// Files with .json extension are compared as JSON, ignoring formatting and key order, others
+// byte for byte. When the test package defines `-update` flag, and it's set, the golden file
+// is written from the response body instead.
+//
+// 		var update = flag.Bool("update", false, "update golden files")
+//
+// 		client.Get("/report").AssertMatchesGolden(t, "testdata/report.golden")
+func (r *Response) AssertMatchesGolden(t TestingT, path string) *Response {
+	t.Helper()
+	if updateGolden() {
+		if err := r.SaveToFile(path); err != nil {
+			t.Errorf("updating golden file: %v", err)
+		}
+		return r
+	}
+
+	golden, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Errorf("reading golden file: %v", err)
+		return r
+	}
+
+	if filepath.Ext(path) == ".json" {
+		var expected, actual interface{}
+		if err := json.Unmarshal(golden, &expected); err != nil {
+			t.Errorf("golden file %s is not JSON: %v", path, err)
+			return r
+		}
+		if err := json.Unmarshal(r.Body, &actual); err != nil || !reflect.DeepEqual(expected, actual) {
+			t.Errorf("expected body to match golden file %s\nexpected: %s\nactual:   %s", path, golden, r.Body)
+		}
+		return r
+	}
+
+	if !bytes.Equal(golden, r.Body) {
+		t.Errorf("expected body to match golden file %s\nexpected: %q\nactual:   %q", path, golden, r.Body)
+	}
+	return r
+}
+
+func updateGolden() bool {
+	f := flag.Lookup("update")
+	if f == nil {
+		return false
+	}
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return false
+	}
+	update, _ := getter.Get().(bool)
+	return update
+}
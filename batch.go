@@ -0,0 +1,32 @@
+package testy
+
+// Request describes a request run by ExecuteBatch, e.g. in a table driven test.
+type Request struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    interface{}
+}
+
+// ExecuteBatch method runs the requests in order, returning their responses in the same order.
+// Each request runs the same way as ExecuteWith, on top of the client request state,
+// without changing the client or affecting the other requests.
+//
+// 		responses := client.ExecuteBatch([]testy.Request{
+// 			{Method: testy.MethodGet, URL: "/users"},
+// 			{Method: testy.MethodPost, URL: "/user", Body: User{Name: "bob"}},
+// 		})
+func (c *Client) ExecuteBatch(reqs []Request) []*Response {
+	responses := make([]*Response, len(reqs))
+	for i, req := range reqs {
+		var opts []RequestOption
+		for header, value := range req.Headers {
+			opts = append(opts, WithHeader(header, value))
+		}
+		if req.Body != nil {
+			opts = append(opts, WithBody(req.Body))
+		}
+		responses[i] = c.ExecuteWith(req.Method, req.URL, opts...)
+	}
+	return responses
+}
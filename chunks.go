@@ -0,0 +1,39 @@
+package testy
+
+import (
+	"bytes"
+	"net/http/httptest"
+)
+
+// chunkRecorder is the recorder of EnableChunks, splitting the body written between flushes.
+type chunkRecorder struct {
+	*httptest.ResponseRecorder
+	chunks  [][]byte
+	pending bytes.Buffer
+}
+
+func (w *chunkRecorder) Write(b []byte) (int, error) {
+	w.pending.Write(b)
+	return w.ResponseRecorder.Write(b)
+}
+
+func (w *chunkRecorder) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *chunkRecorder) Flush() {
+	if w.pending.Len() > 0 {
+		w.chunks = append(w.chunks, append([]byte(nil), w.pending.Bytes()...))
+		w.pending.Reset()
+	}
+	w.ResponseRecorder.Flush()
+}
+
+// Chunks returns the flushed chunks, followed by the rest of the body written after the last flush.
+func (w *chunkRecorder) Chunks() [][]byte {
+	chunks := w.chunks
+	if w.pending.Len() > 0 {
+		chunks = append(chunks, append([]byte(nil), w.pending.Bytes()...))
+	}
+	return chunks
+}
@@ -0,0 +1,76 @@
+package testy
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// debugBodyLimit is the number of body bytes logged in debug mode.
+const debugBodyLimit = 1024
+
+// SetDebug method enables logging of the request and response details, including
+// headers and bodies, to the logger. Large bodies are truncated.
+//
+// 		client.SetDebug(true).Get("/hello")
+func (c *Client) SetDebug(debug bool) *Client {
+	c.debug = debug
+	return c
+}
+
+// SetLogger method sets the writer used for debug logging, which is os.Stderr by default.
+//
+// 		var buf bytes.Buffer
+// 		client.SetDebug(true).SetLogger(&buf)
+func (c *Client) SetLogger(w io.Writer) *Client {
+	c.logger = w
+	return c
+}
+
+func (c *Client) debugLog(request *http.Request, response *Response) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "~~~ REQUEST ~~~\n")
+	fmt.Fprintf(&b, "%s %s\n", request.Method, request.URL.String())
+	writeDebugHeader(&b, request.Header)
+	if request.GetBody != nil {
+		if body, err := request.GetBody(); err == nil {
+			data, _ := ioutil.ReadAll(body)
+			writeDebugBody(&b, data)
+		}
+	} else if request.Body != nil && request.Body != http.NoBody {
+		fmt.Fprintf(&b, "\n(streamed body)\n")
+	}
+
+	fmt.Fprintf(&b, "~~~ RESPONSE ~~~\n")
+	fmt.Fprintf(&b, "%s (%v)\n", response.Status, response.Duration)
+	writeDebugHeader(&b, response.RawResponse.Header)
+	writeDebugBody(&b, response.Body)
+
+	io.WriteString(c.logger, b.String())
+}
+
+func writeDebugHeader(b *strings.Builder, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s: %s\n", k, strings.Join(header[k], ", "))
+	}
+}
+
+func writeDebugBody(b *strings.Builder, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	if len(body) > debugBodyLimit {
+		fmt.Fprintf(b, "\n%s\n... (%d more bytes)\n", body[:debugBodyLimit], len(body)-debugBodyLimit)
+		return
+	}
+	fmt.Fprintf(b, "\n%s\n", body)
+}
@@ -0,0 +1,52 @@
+package testy
+
+// MarshalError is the failure encoding the request, e.g. an unsupported type passed to SetBody,
+// or an ambiguous request body. Execute panics with it, ExecuteE returns it.
+//
+// 		defer func() {
+// 			if err, ok := recover().(*testy.MarshalError); ok {
+// 				...
+// 			}
+// 		}()
+type MarshalError struct {
+	Err error
+}
+
+func (e *MarshalError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *MarshalError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError is the failure decoding the response body, into Result or Error, or when it's
+// not valid gzip. It's available from Response.Err.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// TransportError is the failure building the http.Request, e.g. an invalid method, reading
+// the response body, or following redirects.
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,347 @@
+package examples
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/miketonks/testy"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// mockT records assertion failures instead of failing the test.
+type mockT struct {
+	errors []string
+}
+
+func (t *mockT) Helper() {}
+
+func (t *mockT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *mockT) Fatalf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestResponseAssertStatus(t *testing.T) {
+	response := testy.New(EchoHandler()).Get("/hello")
+
+	mock := &mockT{}
+	assert.Equal(t, response, response.AssertStatus(mock, 200).AssertBodyContains(mock, "world"))
+	assert.Empty(t, mock.errors)
+
+	response.AssertStatus(t, 200).AssertBodyContains(t, "hello")
+
+	response.AssertStatus(mock, 404).AssertBodyContains(mock, "bob")
+	assert.Equal(t, []string{
+		"expected status code 404, got 200",
+		`expected body to contain "bob", got "hello, world!"`,
+	}, mock.errors)
+}
+
+func TestResponseAssertBodyEquals(t *testing.T) {
+	response := testy.New(EchoHandler()).Get("/hello")
+
+	response.AssertBodyEquals(t, "hello, world!").AssertBodyBytesEqual(t, []byte("hello, world!"))
+
+	mock := &mockT{}
+	response.AssertBodyEquals(mock, "hello, bob!")
+	response.AssertBodyBytesEqual(mock, []byte("hello"))
+	testy.New(EchoHandler()).SetBody(strings.Repeat("a", 300)).Post("/echo").AssertBodyEquals(mock, "")
+	assert.Equal(t, []string{
+		`expected body to equal "hello, bob!", got "hello, world!" (first difference at byte 7)`,
+		`expected body to equal "hello", got "hello, world!" (first difference at byte 5)`,
+		`expected body to equal "", got "` + strings.Repeat("a", 256) + `"... (44 more bytes) (first difference at byte 0)`,
+	}, mock.errors)
+}
+
+func TestResponseAssertSuccess(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, _ := strconv.Atoi(r.URL.Query().Get("code"))
+		w.WriteHeader(code)
+	})
+
+	for _, tc := range []struct {
+		code             int
+		success, isError bool
+	}{
+		{200, true, false},
+		{204, true, false},
+		{404, false, true},
+		{500, false, true},
+	} {
+		response := testy.New(handler).SetQueryParam("code", strconv.Itoa(tc.code)).Get("/")
+		assert.Equal(t, tc.code, response.StatusCode)
+		assert.Equal(t, tc.success, response.IsSuccess(), "IsSuccess for %d", tc.code)
+		assert.Equal(t, tc.isError, response.IsError(), "IsError for %d", tc.code)
+
+		mock := &mockT{}
+		response.AssertSuccess(mock)
+		assert.Equal(t, tc.success, len(mock.errors) == 0, "AssertSuccess for %d", tc.code)
+	}
+
+	mock := &mockT{}
+	testy.New(handler).SetQueryParam("code", "404").Get("/").AssertSuccess(mock)
+	assert.Equal(t, []string{"expected success status code, got 404"}, mock.errors)
+}
+
+func TestResponseAssertJSONPath(t *testing.T) {
+	response := testy.New(EchoHandler()).Get("/user")
+
+	response.
+		AssertJSONPath(t, "name", "bob").
+		AssertJSONPath(t, "age", 42).
+		AssertJSONPath(t, "tags", []string{"admin", "dev"}).
+		AssertJSONPath(t, "tags.1", "dev").
+		AssertJSONPath(t, "address.city", "London").
+		AssertJSONPath(t, "address", map[string]string{"city": "London"})
+
+	mock := &mockT{}
+	response.
+		AssertJSONPath(mock, "address.city", "Paris").
+		AssertJSONPath(mock, "address.zip", "E1").
+		AssertJSONPath(mock, "tags.2", "ops").
+		AssertJSONPath(mock, "name.first", "bob")
+	assert.Equal(t, []string{
+		`expected "address.city" to be "Paris", got "London"`,
+		`path "address.zip" not found: no key "zip"`,
+		`path "tags.2" not found: no index "2" in array of length 2`,
+		`path "name.first" not found: "first" is not an object or array`,
+	}, mock.errors)
+
+	mock = &mockT{}
+	testy.New(EchoHandler()).Get("/hello").AssertJSONPath(mock, "name", "bob")
+	assert.Len(t, mock.errors, 1, "non-JSON body is expected to fail")
+}
+
+func TestResponseAssertJSON(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).Get("/user")
+	assert.True(t, response.IsJSON())
+	response.AssertJSON(t)
+
+	mock := &mockT{}
+	response = testy.New(handler).Get("/invalid-json")
+	assert.False(t, response.IsJSON())
+	response.AssertJSON(mock)
+	assert.Equal(t, []string{`expected JSON body, got "{\"name\": \"bob\""`}, mock.errors)
+
+	response = testy.New(handler).Get("/echo")
+	assert.False(t, response.IsJSON(), "empty body is not expected to be JSON")
+}
+
+func TestResponseAssertContentType(t *testing.T) {
+	response := testy.New(EchoHandler()).Get("/user")
+	assert.Equal(t, "application/json; charset=UTF-8", response.GetHeader("Content-Type"))
+
+	mock := &mockT{}
+	response.AssertContentType(mock, "application/json")
+	assert.Empty(t, mock.errors, "params are expected to be ignored")
+
+	response.AssertContentType(mock, "application/xml")
+	testy.New(EchoHandler()).Head("/echo").AssertContentType(mock, "text/plain")
+	assert.Equal(t, []string{
+		`expected Content-Type application/xml, got "application/json; charset=UTF-8"`,
+		`expected Content-Type text/plain, got ""`,
+	}, mock.errors)
+}
+
+func TestResponseAssertRedirect(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusFound)
+		case "/moved":
+			w.Header().Set("Location", "https://example.org/users")
+			w.WriteHeader(http.StatusMovedPermanently)
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	})
+
+	testy.New(handler).Get("/old").AssertStatus(t, 302).AssertRedirect(t, "/new")
+	testy.New(handler).Get("/moved").AssertStatus(t, 301).AssertRedirect(t, "https://example.org/users")
+
+	mock := &mockT{}
+	testy.New(handler).Get("/old").AssertRedirect(mock, "/newer")
+	testy.New(handler).Get("/moved").AssertRedirect(mock, "/users")
+	testy.New(handler).Get("/new").AssertRedirect(mock, "/new")
+	assert.Equal(t, []string{
+		`expected redirect to "/newer", got "/new"`,
+		`expected redirect to "/users", got "https://example.org/users"`,
+		"expected redirect status code, got 200",
+	}, mock.errors)
+}
+
+func TestResponseAssertEmpty(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/content" {
+			w.Write([]byte("content"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	response := testy.New(handler).Delete("/")
+	assert.True(t, response.IsEmpty())
+	response.AssertStatus(t, http.StatusNoContent).AssertEmpty(t)
+	assert.True(t, (&testy.Response{Body: []byte{}}).IsEmpty(), "zero-length body is expected to be empty")
+
+	mock := &mockT{}
+	response = testy.New(handler).Get("/content")
+	assert.False(t, response.IsEmpty())
+	response.AssertEmpty(mock)
+	assert.Equal(t, []string{`expected empty body, got "content"`}, mock.errors)
+}
+
+func TestResponseAssertHeader(t *testing.T) {
+	handler := EchoHandler()
+
+	testy.New(handler).
+		SetHeaders(map[string]string{
+			"Origin":                        "https://example.org",
+			"Access-Control-Request-Method": "PUT",
+		}).
+		Options("/cors/data").
+		AssertStatus(t, 204).
+		AssertHeader(t, "Access-Control-Allow-Origin", "https://example.org").
+		AssertHeader(t, "Access-Control-Allow-Methods", "GET,PUT")
+
+	mock := &mockT{}
+	testy.New(handler).
+		SetHeader("Origin", "https://evil.example").
+		Options("/cors/data").
+		AssertHeader(mock, "Access-Control-Allow-Origin", "https://evil.example")
+	assert.Equal(t, []string{`expected header Access-Control-Allow-Origin to be "https://evil.example", got ""`}, mock.errors)
+}
+
+func TestResponseAssertJSONEquals(t *testing.T) {
+	response := testy.New(EchoHandler()).Get("/user")
+
+	response.AssertJSONEquals(t, `{
+		"tags": ["admin", "dev"],
+		"address": {"city": "London"},
+		"age": 42,
+		"name": "bob"
+	}`)
+
+	mock := &mockT{}
+	response.AssertJSONEquals(mock, `{"name": "alice", "age": 42, "tags": ["admin"], "address": {"city": "London", "zip": "N1"}}`)
+	assert.Equal(t, []string{`expected JSON body to equal {"name": "alice", "age": 42, "tags": ["admin"], "address": {"city": "London", "zip": "N1"}}
+address.zip: missing, expected "N1"
+name: expected "alice", got "bob"
+tags: expected array of length 1, got 2`}, mock.errors)
+
+	mock = &mockT{}
+	testy.New(EchoHandler()).Get("/hello").AssertJSONEquals(mock, `{}`)
+	assert.Equal(t, []string{`expected JSON body, got "hello, world!"`}, mock.errors)
+}
+
+func TestResponseAssertJSONSchema(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"required": ["name", "age"],
+		"properties": {
+			"name": {"type": "string"},
+			"age": {"type": "integer", "minimum": 0},
+			"tags": {"type": "array", "items": {"type": "string"}}
+		}
+	}`
+
+	testy.New(EchoHandler()).Get("/user").AssertJSONSchema(t, schema)
+
+	mock := &mockT{}
+	testy.New(EchoHandler()).Get("/users").AssertJSONSchema(mock, schema)
+	assert.Equal(t, []string{"JSON schema: (root): Invalid type. Expected: object, given: array"}, mock.errors)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"age": "42", "tags": [1]}`)
+	})
+	mock = &mockT{}
+	testy.New(handler).Get("/").AssertJSONSchema(mock, schema)
+	assert.ElementsMatch(t, []string{
+		"JSON schema: (root): name is required",
+		"JSON schema: age: Invalid type. Expected: integer, given: string",
+		"JSON schema: tags.0: Invalid type. Expected: string, given: integer",
+	}, mock.errors)
+
+	mock = &mockT{}
+	testy.New(EchoHandler()).Get("/hello").AssertJSONSchema(mock, schema)
+	assert.Len(t, mock.errors, 1, "non-JSON body is expected to fail")
+}
+
+func TestResponseAssertMatchesGolden(t *testing.T) {
+	handler := EchoHandler()
+
+	testy.New(handler).Get("/hello").AssertMatchesGolden(t, "testdata/hello.golden")
+	testy.New(handler).Get("/user").AssertMatchesGolden(t, "testdata/user.json")
+
+	if *update {
+		return
+	}
+
+	mock := &mockT{}
+	testy.New(handler).SetHeader("X-UserName", "bob").Get("/hello").AssertMatchesGolden(mock, "testdata/hello.golden")
+	testy.New(handler).Get("/users").AssertMatchesGolden(mock, "testdata/user.json")
+	testy.New(handler).Get("/hello").AssertMatchesGolden(mock, "testdata/missing.golden")
+	assert.Len(t, mock.errors, 3)
+	assert.Equal(t, "expected body to match golden file testdata/hello.golden\n"+
+		"expected: \"hello, world!\"\n"+
+		"actual:   \"hello, bob!\"", mock.errors[0])
+}
+
+func TestResponseAssertMatchesGoldenUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testy")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer flag.Set("update", fmt.Sprint(*update))
+	assert.NoError(t, flag.Set("update", "true"))
+
+	path := filepath.Join(dir, "testdata", "hello.golden")
+	testy.New(EchoHandler()).Get("/hello").AssertMatchesGolden(t, path)
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello, world!", string(data))
+}
+
+func TestResponseJSONInto(t *testing.T) {
+	handler := EchoHandler()
+
+	requireCity := func(v interface{}) error {
+		if v.(*User).Address.City == "" {
+			return errors.New("address.city is required")
+		}
+		return nil
+	}
+
+	var user User
+	testy.New(handler).Get("/user").JSONInto(t, &user, requireCity)
+	assert.Equal(t, "London", user.Address.City)
+
+	mock := &mockT{}
+	var users []User
+	testy.New(handler).Get("/users").JSONInto(mock, &users, nil)
+	assert.Empty(t, mock.errors)
+	assert.Len(t, users, 2)
+
+	user = User{}
+	testy.New(handler).SetBody(User{Name: "bob"}).Post("/user").JSONInto(mock, &user, requireCity)
+	assert.Equal(t, []string{"validating JSON body: address.city is required"}, mock.errors)
+
+	mock = &mockT{}
+	testy.New(handler).Get("/invalid-json").JSONInto(mock, &user, requireCity)
+	assert.Len(t, mock.errors, 1, "invalid JSON is expected to fail")
+}
@@ -1,20 +1,90 @@
 package examples
 
 import (
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo"
 )
 
+const (
+	// SessionID is the session cookie value set by /login.
+	SessionID = "7f3c2a"
+
+	// Token is the auth token returned by /login.
+	Token = "secret-token"
+)
+
+// User is returned by the JSON routes of the example handlers.
+type User struct {
+	Name    string   `json:"name"`
+	Age     int      `json:"age"`
+	Tags    []string `json:"tags"`
+	Address Address  `json:"address"`
+}
+
+// Address is nested in User.
+type Address struct {
+	City string `json:"city"`
+}
+
+// ErrorMessage is returned by the JSON routes of the example handlers on failure.
+type ErrorMessage struct {
+	Message string `json:"message"`
+}
+
+// Note is accepted as XML by the example handlers.
+type Note struct {
+	XMLName xml.Name `xml:"note"`
+	To      string   `xml:"to"`
+	Body    string   `xml:"body"`
+}
+
 // EchoHandler creates http.Handler using echo framework.
 //
 // Routes:
-//  GET /login             authenticate user and return JWT token
-//  GET /restricted/hello  return "hello, world!" (requires authentication)
+//  GET /login             authenticate user, set session cookie (secure over TLS) and return token
+//  GET /restricted/hello  return "hello, world!" (requires session cookie or bearer token)
+//  GET /basic            return "<username>:<password>" from basic auth, or 401
+//  GET /users            return JSON array of users
+//  GET /user             return a JSON user with X-Request-Id header
+//  GET /user.gz          return a gzip encoded JSON user
+//  POST /user            create a JSON user, or return a JSON ErrorMessage when name is missing
+//  GET /users/:id         return the escaped request path
+//  GET /invalid-json      return malformed JSON with application/json content type
+//  GET /query            return the raw query string
+//  GET /sleep?ms=<n>      sleep for n milliseconds
+//  GET /wait             wait a second, or return 503 when the request context is done
+//  POST /form            return "hello, <name>!" using the form field name
+//  POST /upload          return "<form field name> uploaded <filename>: <content>" of the multipart file field
+//  POST /note            decode an XML note and return "<to>: <body>"
+//  POST /gunzip          decompress gzip encoded request body and return it
+//  ANY /redirect/:code    redirect to /echo with the given status code
+//  GET /chain            redirect to /redirect/302
+//  GET /loop             redirect to itself
+//  ANY /cors/data         return "data", allowing cross origin requests from https://example.org
+//  ANY /echo              return request method, content type and length in X-Method, X-Content-Type and
+//                         X-Content-Length headers, and request body as response body
 func EchoHandler() http.Handler {
 	e := echo.New()
 
+	e.GET("/login", func(ctx echo.Context) error {
+		ctx.SetCookie(&http.Cookie{
+			Name:     "session",
+			Value:    SessionID,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   ctx.Request().TLS != nil,
+		})
+		return ctx.String(http.StatusOK, Token)
+	})
+
 	e.GET("/hello", func(ctx echo.Context) error {
 		name := ctx.Request().Header.Get("X-UserName")
 		if name == "" {
@@ -23,5 +93,182 @@ func EchoHandler() http.Handler {
 		return ctx.String(http.StatusOK, fmt.Sprintf("hello, %s!", name))
 	})
 
+	e.GET("/basic", func(ctx echo.Context) error {
+		username, password, ok := ctx.Request().BasicAuth()
+		if !ok {
+			return ctx.String(http.StatusUnauthorized, "unauthorized")
+		}
+		return ctx.String(http.StatusOK, fmt.Sprintf("%s:%s", username, password))
+	})
+
+	e.GET("/users", func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, []User{{Name: "alice"}, {Name: "bob"}})
+	})
+
+	e.GET("/user", func(ctx echo.Context) error {
+		ctx.Response().Header().Set("X-Request-Id", "42")
+		return ctx.JSON(http.StatusOK, User{
+			Name:    "bob",
+			Age:     42,
+			Tags:    []string{"admin", "dev"},
+			Address: Address{City: "London"},
+		})
+	})
+
+	e.GET("/user.gz", func(ctx echo.Context) error {
+		ctx.Response().Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		ctx.Response().Header().Set(echo.HeaderContentEncoding, "gzip")
+		ctx.Response().WriteHeader(http.StatusOK)
+		w := gzip.NewWriter(ctx.Response())
+		defer w.Close()
+		return json.NewEncoder(w).Encode(User{Name: "bob", Age: 42})
+	})
+
+	e.POST("/user", func(ctx echo.Context) error {
+		var user User
+		if err := ctx.Bind(&user); err != nil {
+			return ctx.JSON(http.StatusBadRequest, ErrorMessage{Message: err.Error()})
+		}
+		if user.Name == "" {
+			return ctx.JSON(http.StatusBadRequest, ErrorMessage{Message: "name is required"})
+		}
+		return ctx.JSON(http.StatusCreated, user)
+	})
+
+	e.GET("/users/:id", func(ctx echo.Context) error {
+		return ctx.String(http.StatusOK, ctx.Request().URL.EscapedPath())
+	})
+
+	e.GET("/invalid-json", func(ctx echo.Context) error {
+		return ctx.Blob(http.StatusOK, echo.MIMEApplicationJSON, []byte(`{"name": "bob"`))
+	})
+
+	e.GET("/query", func(ctx echo.Context) error {
+		return ctx.String(http.StatusOK, ctx.Request().URL.RawQuery)
+	})
+
+	e.GET("/sleep", func(ctx echo.Context) error {
+		ms, err := strconv.Atoi(ctx.QueryParam("ms"))
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, err.Error())
+		}
+		time.Sleep(time.Duration(ms) * time.Millisecond)
+		return ctx.String(http.StatusOK, "done")
+	})
+
+	e.GET("/wait", func(ctx echo.Context) error {
+		select {
+		case <-ctx.Request().Context().Done():
+			return ctx.String(http.StatusServiceUnavailable, ctx.Request().Context().Err().Error())
+		case <-time.After(time.Second):
+			return ctx.String(http.StatusOK, "done")
+		}
+	})
+
+	restricted := e.Group("/restricted", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if session, err := ctx.Cookie("session"); err == nil && session.Value == SessionID {
+				return next(ctx)
+			}
+			if ctx.Request().Header.Get("Authorization") == "Bearer "+Token {
+				return next(ctx)
+			}
+			return ctx.String(http.StatusUnauthorized, "unauthorized")
+		}
+	})
+	restricted.GET("/hello", func(ctx echo.Context) error {
+		return ctx.String(http.StatusOK, "hello, world!")
+	})
+
+	e.POST("/form", func(ctx echo.Context) error {
+		if err := ctx.Request().ParseForm(); err != nil {
+			return ctx.String(http.StatusBadRequest, err.Error())
+		}
+		name := ctx.Request().PostForm.Get("name")
+		return ctx.String(http.StatusOK, fmt.Sprintf("hello, %s!", name))
+	})
+
+	e.POST("/upload", func(ctx echo.Context) error {
+		file, header, err := ctx.Request().FormFile("file")
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, err.Error())
+		}
+		defer file.Close()
+		content, err := ioutil.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		name := ctx.Request().FormValue("name")
+		return ctx.String(http.StatusOK, fmt.Sprintf("%s uploaded %s: %s", name, header.Filename, content))
+	})
+
+	e.POST("/note", func(ctx echo.Context) error {
+		var note Note
+		if err := xml.NewDecoder(ctx.Request().Body).Decode(&note); err != nil {
+			return ctx.String(http.StatusBadRequest, err.Error())
+		}
+		return ctx.String(http.StatusOK, fmt.Sprintf("%s: %s", note.To, note.Body))
+	})
+
+	e.POST("/gunzip", func(ctx echo.Context) error {
+		if ctx.Request().Header.Get("Content-Encoding") != "gzip" {
+			return ctx.String(http.StatusUnsupportedMediaType, "gzip encoding is required")
+		}
+		r, err := gzip.NewReader(ctx.Request().Body)
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, err.Error())
+		}
+		defer r.Close()
+		body, err := ioutil.ReadAll(r)
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, err.Error())
+		}
+		return ctx.Blob(http.StatusOK, echo.MIMETextPlain, body)
+	})
+
+	e.Any("/redirect/:code", func(ctx echo.Context) error {
+		code, err := strconv.Atoi(ctx.Param("code"))
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, err.Error())
+		}
+		return ctx.Redirect(code, "/echo")
+	})
+
+	e.GET("/chain", func(ctx echo.Context) error {
+		return ctx.Redirect(http.StatusFound, "/redirect/302")
+	})
+
+	e.GET("/loop", func(ctx echo.Context) error {
+		return ctx.Redirect(http.StatusFound, "/loop")
+	})
+
+	e.Any("/cors/data", func(ctx echo.Context) error {
+		if ctx.Request().Header.Get("Origin") == "https://example.org" {
+			ctx.Response().Header().Set("Access-Control-Allow-Origin", "https://example.org")
+			ctx.Response().Header().Set("Access-Control-Allow-Methods", "GET,PUT")
+		}
+		if ctx.Request().Method == http.MethodOptions {
+			return ctx.NoContent(http.StatusNoContent)
+		}
+		return ctx.String(http.StatusOK, "data")
+	})
+
+	e.Any("/echo", func(ctx echo.Context) error {
+		ctx.Response().Header().Set("X-Method", ctx.Request().Method)
+		ctx.Response().Header().Set("X-Content-Type", ctx.Request().Header.Get("Content-Type"))
+		ctx.Response().Header().Set("X-Content-Length", strconv.FormatInt(ctx.Request().ContentLength, 10))
+		if ctx.Request().Method == http.MethodHead {
+			return ctx.NoContent(http.StatusOK)
+		}
+		var body []byte
+		if ctx.Request().Body != nil {
+			var err error
+			if body, err = ioutil.ReadAll(ctx.Request().Body); err != nil {
+				return err
+			}
+		}
+		return ctx.Blob(http.StatusOK, echo.MIMETextPlain, body)
+	})
+
 	return e
 }
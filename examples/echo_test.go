@@ -1,8 +1,18 @@
 package examples
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/miketonks/testy"
 	"github.com/stretchr/testify/assert"
@@ -21,3 +31,1137 @@ func TestEchoClient(t *testing.T) {
 	assert.Equal(t, "hello, bob!", response.String())
 	fmt.Println(response.String())
 }
+
+func TestEchoClientMethods(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).SetBody("updated").Put("/echo")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "PUT", response.RawResponse.Header.Get("X-Method"))
+	assert.Equal(t, "updated", response.String())
+
+	response = testy.New(handler).SetBody("patched").Patch("/echo")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "PATCH", response.RawResponse.Header.Get("X-Method"))
+	assert.Equal(t, "patched", response.String())
+
+	response = testy.New(handler).Head("/echo")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "HEAD", response.RawResponse.Header.Get("X-Method"))
+	assert.Equal(t, "", response.String())
+
+	response = testy.New(handler).Options("/echo")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "OPTIONS", response.RawResponse.Header.Get("X-Method"))
+}
+
+func TestEchoClientFormData(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).SetFormData(map[string]string{"name": "alice"}).Post("/form")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "hello, alice!", response.String())
+
+	response = testy.New(handler).SetFormDataFromValues(url.Values{"name": {"carol", "dave"}}).Post("/form")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "hello, carol!", response.String())
+
+	response = testy.New(handler).SetBody("name=ignored").SetFormData(map[string]string{"name": "erin"}).Post("/form")
+	assert.Equal(t, 200, response.StatusCode, "FormData is expected to win without explicit Content-Type")
+	assert.Equal(t, "hello, erin!", response.String())
+
+	api := testy.New(handler).
+		SetHeader("Content-Type", "text/plain").
+		SetBody("name=frank").
+		SetFormData(map[string]string{"name": "grace"})
+	assert.Panics(t, func() { api.Post("/form") }, "ambiguous body is expected to panic")
+}
+
+func TestEchoClientRequestSize(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).SetBody("hello, world!").Post("/echo")
+	assert.Equal(t, int64(13), response.RequestSize)
+	assert.Equal(t, "13", response.Header().Get("X-Content-Length"))
+
+	response = testy.New(handler).SetFormData(map[string]string{"name": "alice"}).Post("/form")
+	assert.Equal(t, int64(len("name=alice")), response.RequestSize, "encoded form length is expected")
+
+	response = testy.New(handler).
+		SetMultipartBoundary("testy-boundary").
+		SetFileReader("file", "notes.txt", strings.NewReader("x")).
+		Post("/echo")
+	assert.Equal(t, int64(len(response.Body)), response.RequestSize, "multipart length is expected")
+
+	response = testy.New(handler).Get("/hello")
+	assert.Equal(t, int64(0), response.RequestSize)
+}
+
+func TestEchoClientUpload(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).
+		SetFormData(map[string]string{"name": "alice"}).
+		SetFileReader("file", "notes.txt", strings.NewReader("some notes")).
+		Post("/upload")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "alice uploaded notes.txt: some notes", response.String())
+
+	dir, err := ioutil.TempDir("", "testy")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "report.csv")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("a,b,c"), 0644))
+
+	response = testy.New(handler).SetFile("file", path).Post("/upload")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, " uploaded report.csv: a,b,c", response.String())
+
+	response = testy.New(handler).
+		SetMultipartBoundary("testy-boundary").
+		SetFileReader("file", "notes.txt", strings.NewReader("x")).
+		Post("/echo")
+	assert.Equal(t, "--testy-boundary\r\n"+
+		"Content-Disposition: form-data; name=\"file\"; filename=\"notes.txt\"\r\n"+
+		"Content-Type: application/octet-stream\r\n\r\n"+
+		"x\r\n--testy-boundary--\r\n", response.String())
+
+	response = testy.New(handler).
+		SetMultipartBoundary("testy-boundary").
+		SetFormData(map[string]string{"c": "3", "a": "1", "b": "2"}).
+		SetFileReader("file", "notes.txt", strings.NewReader("x")).
+		Post("/echo")
+	field := func(name, value string) string {
+		return "--testy-boundary\r\nContent-Disposition: form-data; name=\"" + name + "\"\r\n\r\n" + value + "\r\n"
+	}
+	assert.True(t, strings.HasPrefix(response.String(), field("a", "1")+field("b", "2")+field("c", "3")),
+		"form fields are expected in sorted order, got %q", response.String())
+}
+
+func TestEchoClientInvalidJSON(t *testing.T) {
+	handler := EchoHandler()
+
+	var result struct {
+		Name string `json:"name"`
+	}
+	response := testy.New(handler).SetResult(&result).Get("/invalid-json")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Error(t, response.Err, "unmarshal error is expected")
+	assert.Equal(t, `{"name": "bob"`, response.String())
+
+	response = testy.New(handler).Get("/invalid-json")
+	assert.NoError(t, response.Err, "no unmarshal is expected without Result")
+}
+
+func TestEchoClientContext(t *testing.T) {
+	handler := EchoHandler()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	response := testy.New(handler).SetContext(ctx).Get("/wait")
+	assert.Equal(t, 503, response.StatusCode, "handler is expected to return early")
+	assert.Equal(t, "context canceled", response.String())
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	response = testy.New(handler).SetContext(ctx).Get("/wait")
+	assert.Equal(t, 503, response.StatusCode, "handler is expected to return early")
+	assert.Equal(t, "context deadline exceeded", response.String())
+}
+
+func TestEchoClientResponseJSON(t *testing.T) {
+	handler := EchoHandler()
+
+	var user User
+	response := testy.New(handler).Get("/user")
+	assert.NoError(t, response.JSON(&user))
+	assert.Equal(t, "bob", user.Name)
+	assert.Equal(t, []string{"admin", "dev"}, user.Tags)
+	assert.Equal(t, "London", user.Address.City)
+
+	assert.Error(t, response.JSON(nil), "nil target is expected to fail")
+
+	response = testy.New(handler).Get("/invalid-json")
+	assert.Error(t, response.JSON(&user), "invalid JSON is expected to fail")
+}
+
+func TestEchoClientResponseHeader(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).Get("/user")
+	assert.Equal(t, "application/json; charset=UTF-8", response.GetHeader("Content-Type"))
+	assert.Equal(t, "42", response.GetHeader("X-Request-Id"))
+	assert.Equal(t, []string{"42"}, response.Header()["X-Request-Id"])
+	assert.Equal(t, "", response.GetHeader("X-Missing"))
+}
+
+func TestEchoClientResponseCookies(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).Get("/login")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Len(t, response.Cookies(), 1)
+
+	session, ok := response.Cookie("session")
+	assert.True(t, ok, "session cookie is expected")
+	assert.Equal(t, SessionID, session.Value)
+	assert.True(t, session.HttpOnly, "session cookie is expected to be http-only")
+	assert.Equal(t, "/", session.Path)
+
+	_, ok = response.Cookie("missing")
+	assert.False(t, ok, "missing cookie is not expected")
+}
+
+func TestEchoClientCookieJar(t *testing.T) {
+	handler := EchoHandler()
+
+	api := testy.New(handler)
+	api.Get("/login")
+	response := api.Get("/restricted/hello")
+	assert.Equal(t, 401, response.StatusCode, "cookies are not expected to persist by default")
+
+	api = testy.New(handler).EnableCookieJar()
+	response = api.Get("/restricted/hello")
+	assert.Equal(t, 401, response.StatusCode, "Unauthorized response is expected before login")
+
+	api.Get("/login")
+	response = api.Get("/restricted/hello")
+	assert.Equal(t, 200, response.StatusCode, "session cookie is expected to be sent")
+	assert.Equal(t, "hello, world!", response.String())
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestEchoClientBodyReader(t *testing.T) {
+	handler := EchoHandler()
+
+	payload := strings.Repeat("streamed payload ", 1000)
+	response := testy.New(handler).SetBody(strings.NewReader(payload)).Post("/echo")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, payload, response.String())
+
+	body := &closeRecorder{Reader: strings.NewReader("closed")}
+	response = testy.New(handler).SetBody(body).Post("/echo")
+	assert.Equal(t, "closed", response.String())
+	assert.True(t, body.closed, "io.ReadCloser body is expected to be closed")
+}
+
+func TestEchoClientBodyXML(t *testing.T) {
+	handler := EchoHandler()
+
+	api := testy.New(handler).SetBodyXML(Note{To: "bob", Body: "hello"})
+	assert.Equal(t, "application/xml", api.Header.Get("Content-Type"))
+	response := api.Post("/note")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "bob: hello", response.String())
+
+	api = testy.New(handler).SetHeader("Content-Type", "text/xml").SetBodyXML(Note{To: "bob"})
+	assert.Equal(t, "text/xml", api.Header.Get("Content-Type"), "explicit Content-Type is not expected to be overridden")
+	response = api.Post("/echo")
+	assert.Equal(t, "<note><to>bob</to><body></body></note>", response.String())
+}
+
+func TestEchoClientBodyJSONContentType(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).SetBody(User{Name: "bob"}).Post("/echo")
+	assert.Equal(t, "application/json", response.GetHeader("X-Content-Type"))
+	assert.Equal(t, `{"name":"bob","age":0,"tags":null,"address":{"city":""}}`, response.String())
+
+	response = testy.New(handler).SetBody(map[string]int{"a": 1}).Post("/echo")
+	assert.Equal(t, "application/json", response.GetHeader("X-Content-Type"))
+
+	response = testy.New(handler).
+		SetHeader("Content-Type", "application/vnd.api+json").
+		SetBody(User{Name: "bob"}).
+		Post("/echo")
+	assert.Equal(t, "application/vnd.api+json", response.GetHeader("X-Content-Type"), "explicit Content-Type is not expected to be overridden")
+
+	response = testy.New(handler).SetBody("plain").Post("/echo")
+	assert.Equal(t, "", response.GetHeader("X-Content-Type"), "string body is not expected to set Content-Type")
+}
+
+func TestEchoClientReset(t *testing.T) {
+	handler := EchoHandler()
+
+	api := testy.New(handler)
+	response := api.SetQueryParam("page", "1").Get("/query")
+	assert.Equal(t, "page=1", response.String())
+
+	response = api.SetQueryParam("size", "10").Get("/query")
+	assert.Equal(t, "page=1&size=10", response.String(), "query params are expected to accumulate without Reset")
+
+	response = api.Reset().SetQueryParam("size", "20").Get("/query")
+	assert.Equal(t, "size=20", response.String(), "query params are not expected to bleed after Reset")
+
+	api.SetHeader("X-UserName", "bob").SetBody("payload").SetFormData(map[string]string{"a": "b"})
+	api.Reset()
+	assert.Empty(t, api.Header)
+	assert.Empty(t, api.FormData)
+	assert.Nil(t, api.Body)
+	assert.Equal(t, "hello, world!", api.Get("/hello").String())
+}
+
+func TestEchoClientBasicAuth(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).Get("/basic")
+	assert.Equal(t, 401, response.StatusCode, "Unauthorized response is expected")
+
+	response = testy.New(handler).SetBasicAuth("alice", "s3cr3t:pass").Get("/basic")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "alice:s3cr3t:pass", response.String())
+}
+
+func TestEchoClientAuthToken(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).Get("/restricted/hello")
+	assert.Equal(t, 401, response.StatusCode, "Unauthorized response is expected without token")
+
+	token := testy.New(handler).Get("/login").String()
+	response = testy.New(handler).SetAuthToken(token).Get("/restricted/hello")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected with token")
+	assert.Equal(t, "hello, world!", response.String())
+
+	response = testy.New(handler).SetAuthToken("wrong").Get("/restricted/hello")
+	assert.Equal(t, 401, response.StatusCode, "Unauthorized response is expected with wrong token")
+}
+
+func TestEchoClientFollowRedirects(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).Get("/redirect/302")
+	assert.Equal(t, 302, response.StatusCode, "redirects are not expected to be followed by default")
+	assert.Equal(t, "/echo", response.GetHeader("Location"))
+
+	response = testy.New(handler).SetFollowRedirects(true).Get("/redirect/302")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "GET", response.GetHeader("X-Method"))
+
+	response = testy.New(handler).SetFollowRedirects(true).SetBody("payload").Post("/redirect/303")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "GET", response.GetHeader("X-Method"), "303 is expected to switch to GET")
+	assert.Equal(t, "", response.String())
+
+	for _, code := range []string{"307", "308"} {
+		response = testy.New(handler).SetFollowRedirects(true).SetBody("payload").Post("/redirect/" + code)
+		assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+		assert.Equal(t, "POST", response.GetHeader("X-Method"), code+" is expected to preserve method")
+		assert.Equal(t, "payload", response.String(), code+" is expected to preserve body")
+	}
+}
+
+func TestEchoClientRedirectLoop(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).SetFollowRedirects(true).Get("/loop")
+	assert.Equal(t, 302, response.StatusCode, "last redirect response is expected")
+	assert.EqualError(t, response.Err, "stopped after 10 redirects")
+
+	response = testy.New(handler).SetFollowRedirects(true).SetMaxRedirects(3).Get("/loop")
+	assert.EqualError(t, response.Err, "stopped after 3 redirects")
+}
+
+func TestEchoClientRedirectChain(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).SetFollowRedirects(true).Get("/chain")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, []string{"/redirect/302", "/echo"}, response.Redirects)
+
+	response = testy.New(handler).Get("/chain")
+	assert.Empty(t, response.Redirects, "redirects are not expected without following")
+
+	response = testy.New(handler).SetFollowRedirects(true).SetMaxRedirects(2).Get("/loop")
+	assert.Equal(t, []string{"/loop", "/loop"}, response.Redirects)
+}
+
+func TestEchoClientDuration(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).SetQueryParam("ms", "20").Get("/sleep")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.True(t, response.Duration >= 20*time.Millisecond, "duration is expected to cover the handler")
+}
+
+func TestEchoClientSetError(t *testing.T) {
+	handler := EchoHandler()
+
+	var user User
+	var errMsg ErrorMessage
+	response := testy.New(handler).SetBody(User{Age: 42}).SetResult(&user).SetError(&errMsg).Post("/user")
+	assert.Equal(t, 400, response.StatusCode, "Bad Request response is expected")
+	assert.NoError(t, response.Err)
+	assert.Equal(t, "name is required", errMsg.Message)
+	assert.Equal(t, User{}, user, "Result is not expected to be populated on error")
+
+	errMsg = ErrorMessage{}
+	response = testy.New(handler).SetBody(User{Name: "bob"}).SetResult(&user).SetError(&errMsg).Post("/user")
+	assert.Equal(t, 201, response.StatusCode, "Created response is expected")
+	assert.Equal(t, "bob", user.Name)
+	assert.Equal(t, ErrorMessage{}, errMsg, "Error is not expected to be populated on success")
+}
+
+func TestEchoClientPathParams(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).SetPathParam("id", "42").Get("/users/{id}")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "/users/42", response.String())
+
+	response = testy.New(handler).SetPathParams(map[string]string{"id": "a b/c"}).Get("/users/{id}")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "/users/a%20b%2Fc", response.String())
+}
+
+func TestNewNilHandler(t *testing.T) {
+	assert.PanicsWithValue(t, "testy: handler is nil", func() { testy.New(nil) })
+}
+
+func TestEchoClientCompression(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).SetBody("compressed payload").Post("/gunzip")
+	assert.Equal(t, 415, response.StatusCode, "Unsupported Media Type response is expected without compression")
+
+	response = testy.New(handler).SetCompression(true).SetBody("compressed payload").Post("/gunzip")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "compressed payload", response.String())
+
+	response = testy.New(handler).SetCompression(true).SetFormData(map[string]string{"name": "bob"}).Post("/gunzip")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "name=bob", response.String())
+}
+
+func TestEchoClientGzipResponse(t *testing.T) {
+	handler := EchoHandler()
+
+	var user User
+	response := testy.New(handler).SetResult(&user).Get("/user.gz")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.NoError(t, response.Err)
+	assert.Equal(t, `{"name":"bob","age":42,"tags":null,"address":{"city":""}}`+"\n", response.String())
+	assert.Equal(t, "bob", user.Name)
+	assert.Equal(t, int64(len(response.Body)), response.Size)
+
+	raw, err := ioutil.ReadAll(response.RawResponse.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x1f, 0x8b}, raw[:2], "compressed bytes are expected in RawResponse")
+}
+
+func TestEchoClientRawResponseBody(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).Get("/users")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+
+	raw, err := ioutil.ReadAll(response.RawResponse.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, response.Body, raw, "full payload is expected in RawResponse")
+	assert.NoError(t, response.RawResponse.Body.Close())
+}
+
+func TestEchoClientDump(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).
+		SetHeader("X-UserName", "bob").
+		SetBody("hello").
+		Post("/echo?page=2")
+
+	dump, err := response.DumpRequest()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(dump, "POST /echo?page=2 HTTP/1.1\r\n"), "method line is expected, got %q", dump)
+	assert.Contains(t, dump, "X-Username: bob\r\n")
+	assert.True(t, strings.HasSuffix(dump, "\r\n\r\nhello"), "body is expected, got %q", dump)
+
+	dump, err = response.DumpResponse()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(dump, "HTTP/1.1 200 OK\r\n"), "status line is expected, got %q", dump)
+	assert.Contains(t, dump, "X-Method: POST\r\n")
+	assert.True(t, strings.HasSuffix(dump, "hello"), "body is expected, got %q", dump)
+
+	dump, err = response.DumpResponse()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(dump, "hello"), "body is expected to be dumped again")
+}
+
+func TestEchoClientOnAfterResponse(t *testing.T) {
+	handler := EchoHandler()
+
+	var codes []int
+	var order []string
+	api := testy.New(handler).
+		OnAfterResponse(func(r *testy.Response) {
+			codes = append(codes, r.StatusCode)
+			order = append(order, "first")
+		}).
+		OnAfterResponse(func(r *testy.Response) {
+			order = append(order, "second")
+		})
+
+	api.Get("/hello")
+	api.Get("/restricted/hello")
+	assert.Equal(t, []int{200, 401}, codes)
+	assert.Equal(t, []string{"first", "second", "first", "second"}, order, "hooks are expected to run in registration order")
+}
+
+func TestEchoClientDebug(t *testing.T) {
+	handler := EchoHandler()
+
+	var buf bytes.Buffer
+	response := testy.New(handler).
+		SetLogger(&buf).
+		SetDebug(true).
+		SetHeader("X-UserName", "bob").
+		SetQueryParam("page", "1").
+		Get("/hello")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+
+	log := buf.String()
+	assert.Contains(t, log, "GET /hello?page=1")
+	assert.Contains(t, log, "X-Username: bob")
+	assert.Contains(t, log, "200 OK")
+	assert.Contains(t, log, "hello, bob!")
+
+	buf.Reset()
+	testy.New(handler).SetLogger(&buf).SetDebug(true).SetBody(strings.Repeat("x", 2000)).Post("/echo")
+	assert.Contains(t, buf.String(), "... (976 more bytes)", "large bodies are expected to be truncated")
+
+	buf.Reset()
+	testy.New(handler).SetLogger(&buf).Get("/hello")
+	assert.Empty(t, buf.String(), "nothing is expected to be logged without debug")
+}
+
+func TestEchoClientQueryParamsFromStruct(t *testing.T) {
+	handler := EchoHandler()
+
+	type filter struct {
+		Name     string   `url:"name"`
+		Page     int      `url:"page,omitempty"`
+		Size     int      `url:"size,omitempty"`
+		Active   bool     `url:"active"`
+		Tags     []string `url:"tag"`
+		Offset   *int     `url:"offset,omitempty"`
+		Limit    *int     `url:"limit"`
+		Ignored  string   `url:"-"`
+		Category string
+		internal string
+	}
+
+	response := testy.New(handler).SetQueryParamsFromStruct(&filter{
+		Name:     "bob",
+		Page:     2,
+		Tags:     []string{"a", "b"},
+		Ignored:  "x",
+		Category: "books",
+		internal: "y",
+	}).Get("/query")
+	assert.Equal(t, "Category=books&active=false&name=bob&page=2&tag=a&tag=b", response.String(), "nil pointers are expected to be skipped")
+
+	offset, limit := 0, 10
+	response = testy.New(handler).SetQueryParamsFromStruct(filter{Offset: &offset, Limit: &limit}).Get("/query")
+	assert.Equal(t, "Category=&active=false&limit=10&name=&offset=0", response.String(), "pointers are expected to be dereferenced")
+
+	assert.Panics(t, func() { testy.New(handler).SetQueryParamsFromStruct("name=bob") })
+}
+
+func TestEchoClientBodyContentNegotiation(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).
+		SetHeader("Content-Type", "application/xml").
+		SetBody(Note{To: "bob", Body: "hello"}).
+		Post("/note")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "bob: hello", response.String())
+
+	response = testy.New(handler).
+		SetHeader("Content-Type", "text/xml; charset=utf-8").
+		SetBody(Note{To: "bob"}).
+		Post("/echo")
+	assert.Equal(t, "<note><to>bob</to><body></body></note>", response.String())
+
+	response = testy.New(handler).
+		SetHeader("Content-Type", "application/json").
+		SetBody(Note{To: "bob"}).
+		Post("/echo")
+	assert.Equal(t, `{"XMLName":{"Space":"","Local":""},"To":"bob","Body":""}`, response.String())
+
+	response = testy.New(handler).SetBody(Note{To: "bob"}).Post("/echo")
+	assert.Equal(t, "application/json", response.GetHeader("X-Content-Type"), "JSON is expected by default")
+	assert.Equal(t, `{"XMLName":{"Space":"","Local":""},"To":"bob","Body":""}`, response.String())
+}
+
+func TestEchoClientExecuteConcurrent(t *testing.T) {
+	handler := EchoHandler()
+
+	api := testy.New(handler).SetHeader("X-UserName", "bob")
+	responses := api.ExecuteConcurrent(50, testy.MethodGet, "/hello")
+	assert.Len(t, responses, 50)
+	for _, response := range responses {
+		assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+		assert.Equal(t, "hello, bob!", response.String())
+	}
+
+	responses = testy.New(handler).SetBody(User{Name: "bob"}).ExecuteConcurrent(10, testy.MethodPost, "/user")
+	for _, response := range responses {
+		assert.Equal(t, 201, response.StatusCode, "Created response is expected")
+	}
+	responses = testy.New(handler).SetBody(strings.NewReader("payload")).ExecuteConcurrent(10, testy.MethodPost, "/echo")
+	for _, response := range responses {
+		assert.Equal(t, "payload", response.String(), "each request is expected to get its own copy of the streamed body")
+	}
+
+	responses = testy.New(handler).
+		SetFormData(map[string]string{"name": "alice"}).
+		SetFileReader("file", "notes.txt", strings.NewReader("some notes")).
+		ExecuteConcurrent(10, testy.MethodPost, "/upload")
+	for _, response := range responses {
+		assert.Equal(t, "alice uploaded notes.txt: some notes", response.String(), "each request is expected to get its own copy of the file")
+	}
+}
+
+func TestEchoClientExecuteConcurrentHistory(t *testing.T) {
+	handler := EchoHandler()
+
+	var hooked []*testy.Response
+	api := testy.New(handler).EnableHistory().OnAfterResponse(func(r *testy.Response) {
+		hooked = append(hooked, r)
+	})
+	responses := api.ExecuteConcurrent(20, testy.MethodGet, "/hello")
+	assert.ElementsMatch(t, responses, api.History(), "concurrent responses are expected in the history")
+	assert.ElementsMatch(t, responses, hooked, "hooks are expected to run for each response")
+
+	clone := api.Clone()
+	clone.Get("/users")
+	api.Get("/user")
+	assert.Len(t, clone.History(), 1, "clone is expected to record its own history")
+	assert.Equal(t, "/users", clone.History()[0].Request.URL.Path)
+	assert.Equal(t, "/user", api.History()[len(api.History())-1].Request.URL.Path)
+}
+
+func TestEchoClientClone(t *testing.T) {
+	handler := EchoHandler()
+
+	base := testy.New(handler).SetHeader("X-UserName", "bob").SetQueryParam("page", "1").SetBody("payload")
+	clone := base.Clone()
+	clone.SetHeader("X-UserName", "alice").SetQueryParam("size", "10")
+	clone.Body[0] = 'P'
+
+	assert.Equal(t, "bob", base.Header.Get("X-UserName"), "original Header is not expected to change")
+	assert.Equal(t, "page=1", base.QueryParam.Encode(), "original QueryParam is not expected to change")
+	assert.Equal(t, "payload", string(base.Body), "original Body is not expected to change")
+
+	assert.Equal(t, "hello, bob!", base.Get("/hello").String())
+	assert.Equal(t, "hello, alice!", clone.Get("/hello").String())
+}
+
+func TestEchoClientResponseRequest(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).
+		SetPathParam("id", "42").
+		SetQueryParams(map[string]string{"search": "kitchen papers", "size": "large"}).
+		SetAuthToken(Token).
+		Get("/users/{id}")
+	assert.Equal(t, "GET", response.Request.Method)
+	assert.Equal(t, "/users/42", response.Request.URL.Path)
+	assert.Equal(t, "search=kitchen+papers&size=large", response.Request.URL.RawQuery)
+	assert.Equal(t, "Bearer "+Token, response.Request.Header.Get("Authorization"))
+
+	response = testy.New(handler).SetFollowRedirects(true).Get("/chain")
+	assert.Equal(t, "/echo", response.Request.URL.Path, "final request is expected after redirects")
+}
+
+func TestEchoClientRawQuery(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).SetRawQuery("b=2&a=1&b=3&c=%zz").Get("/query")
+	assert.Equal(t, "b=2&a=1&b=3&c=%zz", response.String())
+
+	response = testy.New(handler).SetQueryParam("page", "1").SetRawQuery("b=2&a=1").Get("/query")
+	assert.Equal(t, "b=2&a=1", response.String(), "raw query is expected to override query params")
+}
+
+func TestEchoClientQueryParamNoEscape(t *testing.T) {
+	handler := EchoHandler()
+
+	api := testy.New(handler).
+		SetQueryParamNoEscape("status", "a,b,c").
+		SetQueryParam("q", "x,y")
+	response := api.Get("/query")
+	assert.Equal(t, "q=x%2Cy&status=a,b,c", response.String(), "only the no-escape param is expected verbatim")
+
+	response = api.Reset().SetQueryParam("status", "a,b,c").Get("/query")
+	assert.Equal(t, "status=a%2Cb%2Cc", response.String(), "Reset is expected to clear no-escape params")
+}
+
+func TestEchoClientQueryStringError(t *testing.T) {
+	handler := EchoHandler()
+
+	api := testy.New(handler).SetQueryString("page=1&size=10")
+	assert.NoError(t, api.LastError())
+	assert.Equal(t, "page=1&size=10", api.Get("/query").String())
+
+	api = testy.New(handler).SetQueryString("page=%zz")
+	assert.Error(t, api.LastError(), "invalid query string is expected to be observable")
+	assert.Equal(t, "", api.Get("/query").String())
+
+	assert.NoError(t, api.Reset().LastError())
+}
+
+func TestEchoClientJSONDecoderOptions(t *testing.T) {
+	handler := EchoHandler()
+
+	var name struct {
+		Name string `json:"name"`
+	}
+	response := testy.New(handler).SetResult(&name).Get("/user")
+	assert.NoError(t, response.Err, "unknown fields are expected to be ignored by default")
+	assert.Equal(t, "bob", name.Name)
+
+	response = testy.New(handler).SetJSONDecoderOptions(true, false).SetResult(&name).Get("/user")
+	assert.EqualError(t, response.Err, `json: unknown field "age"`)
+
+	var user User
+	response = testy.New(handler).SetJSONDecoderOptions(true, false).SetResult(&user).Get("/user")
+	assert.NoError(t, response.Err)
+
+	var generic map[string]interface{}
+	response = testy.New(handler).SetJSONDecoderOptions(false, true).SetResult(&generic).Get("/user")
+	assert.NoError(t, response.Err)
+	assert.Equal(t, json.Number("42"), generic["age"])
+}
+
+func TestEchoClientJSONCodec(t *testing.T) {
+	handler := EchoHandler()
+
+	var calls []string
+	marshal := func(v interface{}) ([]byte, error) {
+		calls = append(calls, "marshal")
+		return json.Marshal(v)
+	}
+	unmarshal := func(data []byte, v interface{}) error {
+		calls = append(calls, "unmarshal")
+		return json.Unmarshal(data, v)
+	}
+
+	var user User
+	response := testy.New(handler).
+		SetJSONMarshaler(marshal).
+		SetJSONUnmarshaler(unmarshal).
+		SetBody(User{Name: "bob"}).
+		SetResult(&user).
+		Post("/user")
+	assert.Equal(t, 201, response.StatusCode, "Created response is expected")
+	assert.NoError(t, response.Err)
+	assert.Equal(t, "bob", user.Name)
+	assert.Equal(t, []string{"marshal", "unmarshal"}, calls)
+
+	response = testy.New(handler).
+		SetJSONMarshaler(func(interface{}) ([]byte, error) { return []byte(`{"name":"alice"}`), nil }).
+		SetBody(User{Name: "bob"}).
+		Post("/echo")
+	assert.Equal(t, `{"name":"alice"}`, response.String())
+}
+
+func TestEchoClientContentLength(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).SetBody("hello").Post("/echo")
+	assert.Equal(t, "5", response.GetHeader("X-Content-Length"), "content length is expected to be inferred")
+
+	response = testy.New(handler).SetBody("hello").SetContentLength(42).Post("/echo")
+	assert.Equal(t, "42", response.GetHeader("X-Content-Length"))
+
+	response = testy.New(handler).SetBody(&closeRecorder{Reader: strings.NewReader("hello")}).SetContentLength(-1).Post("/echo")
+	assert.Equal(t, "-1", response.GetHeader("X-Content-Length"))
+	assert.Equal(t, "hello", response.String())
+}
+
+func TestEchoClientMaxBodySize(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).SetMaxBodySize(10).SetBody(strings.Repeat("x", 10)).Post("/echo")
+	assert.Equal(t, 200, response.StatusCode, "body at the limit is expected to pass")
+
+	api := testy.New(handler).SetMaxBodySize(10).SetBody(strings.Repeat("x", 11))
+	assert.Panics(t, func() { api.Post("/echo") }, "body over the limit is expected to panic")
+	_, err := api.ExecuteE(testy.MethodPost, "/echo")
+	assert.EqualError(t, err, "request body of 11 bytes exceeds max body size of 10 bytes")
+
+	api = testy.New(handler).SetMaxBodySize(10).SetFormData(map[string]string{"name": "bobby-tables"})
+	assert.Panics(t, func() { api.Post("/form") }, "form data over the limit is expected to panic")
+
+	api = testy.New(handler).SetMaxBodySize(10).SetBody(bytes.NewReader(bytes.Repeat([]byte("x"), 11)))
+	assert.Panics(t, func() { api.Post("/echo") }, "reader with Len over the limit is expected to panic")
+
+	response = testy.New(handler).SetMaxBodySize(10).SetBody(ioutil.NopCloser(strings.NewReader(strings.Repeat("x", 11)))).Post("/echo")
+	assert.Equal(t, 200, response.StatusCode, "reader without Len is expected to be streamed unchecked")
+
+	response = testy.New(handler).SetBody(strings.Repeat("x", 1<<16)).Post("/echo")
+	assert.Equal(t, 200, response.StatusCode, "body size is expected to be unlimited by default")
+}
+
+func TestEchoClientMaxResponseSize(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).SetMaxResponseSize(10).SetBody(strings.Repeat("x", 100)).Post("/echo")
+	assert.True(t, response.Truncated, "response over the limit is expected to be truncated")
+	assert.Equal(t, strings.Repeat("x", 10), response.String())
+	assert.Equal(t, int64(10), response.Size)
+
+	response = testy.New(handler).SetMaxResponseSize(10).SetBody(strings.Repeat("x", 10)).Post("/echo")
+	assert.False(t, response.Truncated, "response at the limit is not expected to be truncated")
+	assert.Equal(t, strings.Repeat("x", 10), response.String())
+
+	response = testy.New(handler).SetBody(strings.Repeat("x", 100)).Post("/echo")
+	assert.False(t, response.Truncated, "response size is expected to be unlimited by default")
+	assert.Equal(t, int64(100), response.Size)
+}
+
+func TestEchoClientExecuteE(t *testing.T) {
+	handler := EchoHandler()
+
+	response, err := testy.New(handler).ExecuteE(testy.MethodGet, "/hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello, world!", response.String())
+
+	response, err = testy.New(handler).ExecuteE(testy.MethodGet, "/hello%zz")
+	assert.Error(t, err, "invalid URL is expected to fail")
+	assert.Nil(t, response)
+
+	response, err = testy.New(handler).ExecuteE("BAD METHOD", "/hello")
+	assert.EqualError(t, err, `net/http: invalid method "BAD METHOD"`)
+	assert.Nil(t, response)
+
+	assert.Panics(t, func() { testy.New(handler).Execute("BAD METHOD", "/hello") }, "Execute is expected to panic")
+
+	var user User
+	response, err = testy.New(handler).SetResult(&user).ExecuteE(testy.MethodGet, "/invalid-json")
+	assert.Error(t, err, "decoding error is expected to be returned")
+	assert.Equal(t, err, response.Err)
+	assert.Equal(t, `{"name": "bob"`, response.String())
+}
+
+func TestEchoClientTypedErrors(t *testing.T) {
+	handler := EchoHandler()
+
+	recovered := func(fn func()) (v interface{}) {
+		defer func() { v = recover() }()
+		fn()
+		return nil
+	}
+
+	v := recovered(func() { testy.New(handler).SetBody(42) })
+	marshalErr, ok := v.(*testy.MarshalError)
+	assert.True(t, ok, "*MarshalError is expected, got %T", v)
+	assert.EqualError(t, marshalErr, "unsupported 'Body' type/value")
+
+	v = recovered(func() { testy.New(handler).SetBody(map[string]interface{}{"f": func() {}}) })
+	marshalErr, ok = v.(*testy.MarshalError)
+	assert.True(t, ok, "*MarshalError is expected, got %T", v)
+	_, ok = marshalErr.Err.(*json.UnsupportedTypeError)
+	assert.True(t, ok, "underlying JSON error is expected")
+
+	v = recovered(func() { testy.New(handler).SetBody("x").SetFileReader("file", "x.txt", strings.NewReader("x")).Post("/upload") })
+	_, ok = v.(*testy.MarshalError)
+	assert.True(t, ok, "*MarshalError is expected for ambiguous body, got %T", v)
+
+	v = recovered(func() { testy.New(handler).SetFile("file", "testdata/missing.txt") })
+	marshalErr, ok = v.(*testy.MarshalError)
+	assert.True(t, ok, "*MarshalError is expected for missing file, got %T", v)
+	assert.True(t, os.IsNotExist(marshalErr.Err), "underlying file error is expected")
+
+	v = recovered(func() { testy.New(handler).ExecuteMethod("GTE", "/hello") })
+	transportErr, ok := v.(*testy.TransportError)
+	assert.True(t, ok, "*TransportError is expected for unknown method, got %T", v)
+	assert.EqualError(t, transportErr, `unknown method "GTE"`)
+
+	v = recovered(func() { testy.New(handler).Execute("BAD METHOD", "/hello") })
+	transportErr, ok = v.(*testy.TransportError)
+	assert.True(t, ok, "*TransportError is expected, got %T", v)
+	assert.EqualError(t, transportErr, `net/http: invalid method "BAD METHOD"`)
+
+	response := testy.New(handler).SetFollowRedirects(true).Get("/loop")
+	_, ok = response.Err.(*testy.TransportError)
+	assert.True(t, ok, "*TransportError is expected for redirect loop, got %T", response.Err)
+
+	var user User
+	_, err := testy.New(handler).SetResult(&user).ExecuteE(testy.MethodGet, "/invalid-json")
+	decodeErr, ok := err.(*testy.DecodeError)
+	assert.True(t, ok, "*DecodeError is expected, got %T", err)
+	_, ok = decodeErr.Err.(*json.SyntaxError)
+	assert.True(t, ok, "underlying JSON error is expected")
+
+	response = testy.New(handler).SetResult(&user).Get("/user")
+	assert.Nil(t, response.Err, "nil error is expected to stay untyped nil")
+}
+
+func TestEchoClientTLS(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).Get("/login")
+	session, _ := response.Cookie("session")
+	assert.False(t, session.Secure, "session cookie is not expected to be secure without TLS")
+	assert.Nil(t, response.Request.TLS)
+
+	response = testy.New(handler).SetTLS(true).Get("/login")
+	session, _ = response.Cookie("session")
+	assert.True(t, session.Secure, "session cookie is expected to be secure over TLS")
+	assert.NotNil(t, response.Request.TLS)
+	assert.Equal(t, "https", response.Request.URL.Scheme)
+	assert.Equal(t, "https://example.com/login", response.Request.URL.String())
+
+	response = testy.New(handler).SetTLS(true).SetHost("api.example.org").Get("/login")
+	assert.Equal(t, "https://api.example.org/login", response.Request.URL.String())
+
+	response = testy.New(handler).SetTLS(true).SetFollowRedirects(true).Get("/chain")
+	assert.Equal(t, []string{"https://example.com/redirect/302", "https://example.com/echo"}, response.Redirects)
+
+	api := testy.New(handler).SetTLS(true).EnableCookieJar()
+	api.Get("/login")
+	response = api.Get("/restricted/hello")
+	assert.Equal(t, 200, response.StatusCode, "secure cookie is expected to be sent over TLS")
+}
+
+func TestEchoClientHistory(t *testing.T) {
+	handler := EchoHandler()
+
+	api := testy.New(handler)
+	api.Get("/hello")
+	assert.Empty(t, api.History(), "history is not expected to be recorded by default")
+
+	api = testy.New(handler).EnableHistory().EnableCookieJar()
+	api.Get("/restricted/hello")
+	api.Get("/login")
+	api.Get("/restricted/hello")
+
+	history := api.History()
+	assert.Len(t, history, 3)
+	assert.Equal(t, 401, history[0].StatusCode)
+	assert.Equal(t, "/login", history[1].Request.URL.Path)
+	assert.Equal(t, 200, history[2].StatusCode)
+}
+
+func TestEchoClientResponseJSONMap(t *testing.T) {
+	handler := EchoHandler()
+
+	m, err := testy.New(handler).Get("/user").JSONMap()
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", m["name"])
+	assert.Equal(t, float64(42), m["age"])
+
+	s, err := testy.New(handler).Get("/users").JSONSlice()
+	assert.NoError(t, err)
+	assert.Len(t, s, 2)
+	assert.Equal(t, "alice", s[0].(map[string]interface{})["name"])
+
+	_, err = testy.New(handler).Get("/users").JSONMap()
+	assert.Error(t, err, "array body is not expected to decode into map")
+
+	_, err = testy.New(handler).Get("/hello").JSONMap()
+	assert.Error(t, err, "non-JSON body is expected to fail")
+
+	_, err = testy.New(handler).Get("/hello").JSONSlice()
+	assert.Error(t, err, "non-JSON body is expected to fail")
+}
+
+func TestEchoClientSaveToFile(t *testing.T) {
+	handler := EchoHandler()
+
+	dir, err := ioutil.TempDir("", "testy")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	response := testy.New(handler).Get("/user")
+	path := filepath.Join(dir, "golden", "user.json")
+	assert.NoError(t, response.SaveToFile(path))
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, response.Body, data)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
+
+func TestEchoClientPrettyString(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).Get("/users")
+	assert.Equal(t, `[
+  {
+    "name": "alice",
+    "age": 0,
+    "tags": null,
+    "address": {
+      "city": ""
+    }
+  },
+  {
+    "name": "bob",
+    "age": 0,
+    "tags": null,
+    "address": {
+      "city": ""
+    }
+  }
+]
+`, response.PrettyString())
+
+	response = testy.New(handler).Get("/hello")
+	assert.Equal(t, "hello, world!", response.PrettyString(), "non-JSON body is expected unchanged")
+
+	response = testy.New(handler).Get("/invalid-json")
+	assert.Equal(t, `{"name": "bob"`, response.PrettyString(), "invalid JSON body is expected unchanged")
+}
+
+func TestEchoClientExecuteWith(t *testing.T) {
+	handler := EchoHandler()
+
+	api := testy.New(handler).EnableHistory()
+	response := api.ExecuteWith(testy.MethodGet, "/hello", testy.WithHeader("X-UserName", "bob"))
+	assert.Equal(t, "hello, bob!", response.String())
+
+	response = api.Get("/hello")
+	assert.Equal(t, "hello, world!", response.String(), "WithHeader is not expected to persist on the client")
+	assert.Empty(t, api.Header)
+
+	response = api.ExecuteWith(testy.MethodGet, "/query", testy.WithQueryParam("page", "2"))
+	assert.Equal(t, "page=2", response.String())
+	assert.Empty(t, api.QueryParam)
+
+	response = api.ExecuteWith(testy.MethodPost, "/echo", testy.WithBody("payload"))
+	assert.Equal(t, "payload", response.String())
+	assert.Nil(t, api.Body)
+
+	assert.Len(t, api.History(), 4, "ExecuteWith responses are expected in the client history")
+}
+
+func TestEchoClientExecuteMethod(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).ExecuteMethod(testy.MethodGet, "/hello")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+
+	response = testy.New(handler).ExecuteMethod(testy.MethodPatch, "/echo")
+	assert.Equal(t, "PATCH", response.Header().Get("X-Method"))
+
+	assert.Panics(t, func() {
+		testy.New(handler).ExecuteMethod("GTE", "/hello")
+	}, "unknown method is expected to panic")
+}
+
+func TestEchoClientTimeout(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).SetTimeout(20*time.Millisecond).SetQueryParam("ms", "500").Get("/sleep")
+	assert.Equal(t, 504, response.StatusCode, "Gateway Timeout response is expected")
+	assert.Equal(t, "handler timed out after 20ms", response.String())
+	_, ok := response.Err.(*testy.TransportError)
+	assert.True(t, ok, "*TransportError is expected, got %T", response.Err)
+	assert.True(t, response.Duration < 500*time.Millisecond, "handler is not expected to be awaited")
+
+	response = testy.New(handler).SetTimeout(time.Second).SetQueryParam("ms", "1").Get("/sleep")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.NoError(t, response.Err)
+	assert.Equal(t, "done", response.String())
+}
+
+func TestEchoClientExecuteBatch(t *testing.T) {
+	handler := EchoHandler()
+
+	api := testy.New(handler).EnableHistory()
+	responses := api.ExecuteBatch([]testy.Request{
+		{Method: testy.MethodGet, URL: "/hello", Headers: map[string]string{"X-UserName": "alice"}},
+		{Method: testy.MethodPost, URL: "/user", Body: User{Name: "bob"}},
+		{Method: testy.MethodPost, URL: "/user", Body: User{}},
+	})
+	assert.Len(t, responses, 3)
+
+	assert.Equal(t, 200, responses[0].StatusCode, "OK response is expected")
+	assert.Equal(t, "hello, alice!", responses[0].String())
+	assert.Equal(t, 201, responses[1].StatusCode, "Created response is expected")
+	assert.Equal(t, 400, responses[2].StatusCode, "Bad Request response is expected")
+
+	assert.Empty(t, responses[1].Request.Header.Get("X-UserName"), "headers are not expected to leak between requests")
+	assert.Empty(t, api.Header, "client is not expected to change")
+	assert.Len(t, api.History(), 3)
+}
+
+func TestEchoClientArtificialLatency(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).SetArtificialLatency(30 * time.Millisecond).Get("/hello")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.True(t, response.Duration >= 30*time.Millisecond, "latency is expected in Duration, got %s", response.Duration)
+
+	response = testy.New(handler).
+		SetArtificialLatency(200 * time.Millisecond).
+		SetTimeout(20 * time.Millisecond).
+		Get("/hello")
+	assert.Equal(t, 504, response.StatusCode, "latency is expected to count towards the timeout")
+}
+
+func TestEchoClientWithT(t *testing.T) {
+	handler := EchoHandler()
+
+	response := testy.New(handler).WithT(t).Get("/hello")
+	assert.Equal(t, "hello, world!", response.String())
+
+	for _, tc := range []struct {
+		name  string
+		setup func(*testy.Client)
+		fatal string
+	}{
+		{"valid body", func(c *testy.Client) { c.SetBody(User{Name: "bob"}) }, ""},
+		{"unsupported body", func(c *testy.Client) { c.SetBody(42) }, "testy: SetBody: unsupported 'Body' type/value"},
+		{"ambiguous body", func(c *testy.Client) {
+			c.SetBody("x").SetFileReader("file", "x.txt", strings.NewReader("x"))
+		}, "testy: POST /user: ambiguous request body: both 'Body' and 'Files' are set"},
+		{"missing file", func(c *testy.Client) { c.SetFile("file", "testdata/missing.txt") },
+			"testy: SetFile: open testdata/missing.txt: no such file or directory"},
+		{"unsupported query struct", func(c *testy.Client) { c.SetQueryParamsFromStruct(42) },
+			"testy: SetQueryParamsFromStruct: unsupported 'QueryParams' type/value"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := &mockT{}
+			api := testy.New(handler).WithT(mock)
+			tc.setup(api)
+			response := api.Post("/user")
+			if tc.fatal == "" {
+				assert.Empty(t, mock.errors)
+				assert.Equal(t, 201, response.StatusCode, "Created response is expected")
+			} else {
+				assert.Equal(t, []string{tc.fatal}, mock.errors)
+			}
+		})
+	}
+
+	mock := &mockT{}
+	testy.New(handler).WithT(mock).ExecuteMethod("GTE", "/hello")
+	assert.Equal(t, []string{`testy: GTE /hello: unknown method "GTE"`}, mock.errors)
+	mock = &mockT{}
+	hooked := 0
+	api := testy.New(handler).WithT(mock).SetFailBeforeHandler(io.EOF).OnAfterResponse(func(*testy.Response) {
+		hooked++
+	})
+	api.ExecuteConcurrent(3, testy.MethodGet, "/hello")
+	assert.Equal(t, []string{"testy: GET /hello: EOF"}, mock.errors, "concurrent panics are expected to be reported once")
+	assert.Equal(t, 0, hooked, "hooks are not expected to run without a response")
+
+	api = testy.New(handler).SetFailBeforeHandler(io.EOF)
+	assert.Panics(t, func() { api.ExecuteConcurrent(3, testy.MethodGet, "/hello") }, "concurrent panic is expected to be passed on")
+}
@@ -0,0 +1,25 @@
+//go:build go1.18
+// +build go1.18
+
+package examples
+
+import (
+	"testing"
+
+	"github.com/miketonks/testy"
+)
+
+func FuzzExecute(f *testing.F) {
+	f.Add("POST", "/user", []byte(`{"name": "bob"}`))
+	f.Add("POST", "/user", []byte(`{"name": `))
+	f.Add("GET", "/users/%zz", []byte(nil))
+	f.Add("BAD METHOD", "/echo", []byte("x"))
+
+	client := testy.New(EchoHandler())
+	f.Fuzz(func(t *testing.T, method, url string, body []byte) {
+		response, err := client.ExecuteFuzz(method, url, body)
+		if err == nil && response == nil {
+			t.Fatal("response or error is expected")
+		}
+	})
+}
@@ -0,0 +1,880 @@
+package examples
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miketonks/testy"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyHandler fails with 503 for the given number of requests before succeeding,
+// returning the request body.
+func flakyHandler(failures int) (http.Handler, *int) {
+	attempts := 0
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}), &attempts
+}
+
+func TestClientRetry(t *testing.T) {
+	handler, attempts := flakyHandler(2)
+	response := testy.New(handler).SetRetry(3).SetBody("payload").Post("/")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected after retries")
+	assert.Equal(t, "payload", response.String(), "body is expected to be sent on each attempt")
+	assert.Equal(t, 3, *attempts)
+
+	handler, attempts = flakyHandler(5)
+	response = testy.New(handler).SetRetry(2).Get("/")
+	assert.Equal(t, 503, response.StatusCode, "last failed response is expected")
+	assert.Equal(t, 3, *attempts)
+
+	handler, attempts = flakyHandler(1)
+	response = testy.New(handler).SetRetry(2, http.StatusTooManyRequests).Get("/")
+	assert.Equal(t, 503, response.StatusCode, "status not in retryOn is not expected to be retried")
+	assert.Equal(t, 1, *attempts)
+
+	handler, attempts = flakyHandler(1)
+	response = testy.New(handler).Get("/")
+	assert.Equal(t, 503, response.StatusCode, "no retries are expected by default")
+	assert.Equal(t, 1, *attempts)
+}
+
+func TestClientRetryReplaysBody(t *testing.T) {
+	var bodies []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(body)
+	})
+
+	response := testy.New(handler).SetRetry(3).SetBody(strings.NewReader("streamed")).Post("/")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected after retries")
+	assert.Equal(t, []string{"streamed", "streamed", "streamed"}, bodies, "streamed body is expected on each attempt")
+
+	bodies = nil
+	response = testy.New(handler).
+		SetRetry(3).
+		SetMultipartBoundary("b").
+		SetFileReader("file", "notes.txt", strings.NewReader("some notes")).
+		Post("/")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected after retries")
+	assert.Len(t, bodies, 3)
+	for _, body := range bodies {
+		assert.Contains(t, body, "some notes", "file is expected on each attempt")
+	}
+}
+
+// headerHandler returns the value of the given request header.
+func headerHandler(key string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Join(r.Header.Values(key), ",")))
+	})
+}
+
+func TestClientOnBeforeRequest(t *testing.T) {
+	var calls []string
+	api := testy.New(headerHandler("X-Trace-Id")).
+		OnBeforeRequest(func(r *http.Request) {
+			calls = append(calls, "first")
+			r.Header.Set("X-Trace-Id", "abc")
+		}).
+		OnBeforeRequest(func(r *http.Request) {
+			calls = append(calls, "second")
+			r.Header.Add("X-Trace-Id", "def")
+		})
+
+	response := api.Get("/")
+	assert.Equal(t, "abc,def", response.String())
+	assert.Equal(t, []string{"first", "second"}, calls, "hooks are expected to run in registration order")
+}
+
+func TestClientHeaderNotShared(t *testing.T) {
+	api := testy.New(headerHandler("X-Trace-Id")).
+		SetHeader("X-Trace-Id", "abc").
+		SetFormData(map[string]string{"name": "bob"}).
+		OnBeforeRequest(func(r *http.Request) {
+			r.Header.Add("X-Trace-Id", "def")
+			r.Header.Set("X-Signature", "sig")
+		})
+
+	response := api.Post("/")
+	assert.Equal(t, "abc,def", response.String())
+	assert.Equal(t, http.Header{"X-Trace-Id": {"abc"}}, api.Header, "client Header is not expected to change")
+
+	response = api.Post("/")
+	assert.Equal(t, "abc,def", response.String(), "hook changes are not expected to accumulate")
+}
+
+func TestClientRemoteAddr(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.RemoteAddr, "10.") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(r.RemoteAddr))
+	})
+
+	response := testy.New(handler).Get("/")
+	assert.Equal(t, 403, response.StatusCode, "Forbidden response is expected without remote address")
+
+	response = testy.New(handler).SetRemoteAddr("192.168.0.1:1234").Get("/")
+	assert.Equal(t, 403, response.StatusCode, "Forbidden response is expected for external address")
+
+	response = testy.New(handler).SetRemoteAddr("10.0.0.1:1234").Get("/")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected for internal address")
+	assert.Equal(t, "10.0.0.1:1234", response.String())
+}
+
+func TestClientHost(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host {
+		case "a.example.com":
+			w.Write([]byte("site a"))
+		case "b.example.com":
+			w.Write([]byte("site b"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	response := testy.New(handler).SetHeader("Host", "a.example.com").Get("/")
+	assert.Equal(t, 404, response.StatusCode, "Host header is not expected to set the host")
+
+	response = testy.New(handler).SetHost("a.example.com").Get("/")
+	assert.Equal(t, "site a", response.String())
+
+	response = testy.New(handler).SetHost("b.example.com").Get("/")
+	assert.Equal(t, "site b", response.String())
+}
+
+func TestClientAddHeader(t *testing.T) {
+	response := testy.New(headerHandler("X-Tag")).
+		AddHeader("X-Tag", "a").
+		AddHeader("X-Tag", "b").
+		AddHeader("X-Tag", "c").
+		Get("/")
+	assert.Equal(t, "a,b,c", response.String())
+
+	response = testy.New(headerHandler("X-Tag")).
+		AddHeader("X-Tag", "a").
+		SetHeader("X-Tag", "b").
+		Get("/")
+	assert.Equal(t, "b", response.String(), "SetHeader is expected to replace added values")
+}
+
+func TestResponseTrailer(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Write([]byte("streamed data"))
+		w.Header().Set("X-Checksum", "abc123")
+	})
+
+	response := testy.New(handler).Get("/")
+	assert.Equal(t, "streamed data", response.String())
+	assert.Equal(t, "abc123", response.Trailer().Get("X-Checksum"))
+	assert.Equal(t, "", response.GetHeader("X-Checksum"), "trailer is not expected in headers")
+}
+
+func TestClientHeadSize(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1234")
+		if r.Method != http.MethodHead {
+			w.Write(make([]byte, 1234))
+		}
+	})
+
+	response := testy.New(handler).Head("/")
+	assert.Empty(t, response.Body)
+	assert.Equal(t, int64(1234), response.Size, "size is expected from Content-Length")
+
+	response = testy.New(handler).Get("/")
+	assert.Equal(t, int64(1234), response.Size)
+}
+
+func TestClientExecuteStream(t *testing.T) {
+	next := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for i, chunk := range []string{"one", "two", "three"} {
+			if i > 0 {
+				<-next
+			}
+			fmt.Fprintln(w, chunk)
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	response, body := testy.New(handler).ExecuteStream(testy.MethodGet, "/")
+	defer body.Close()
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "application/x-ndjson", response.GetHeader("Content-Type"))
+
+	var lines []string
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) < 3 {
+			// the handler only writes the next chunk once this one was read
+			next <- struct{}{}
+		}
+	}
+	assert.NoError(t, scanner.Err())
+	assert.Equal(t, []string{"one", "two", "three"}, lines)
+}
+
+func TestClientExecuteStreamPanic(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/late" {
+			fmt.Fprintln(w, "one")
+			w.(http.Flusher).Flush()
+		}
+		panic("boom")
+	})
+
+	assert.PanicsWithValue(t, "boom", func() {
+		testy.New(handler).ExecuteStream(testy.MethodGet, "/")
+	}, "panic before the header is expected to be passed on")
+
+	response, body := testy.New(handler).ExecuteStream(testy.MethodGet, "/late")
+	defer body.Close()
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	data, err := ioutil.ReadAll(body)
+	assert.Equal(t, "one\n", string(data))
+	_, ok := err.(*testy.TransportError)
+	assert.True(t, ok, "*TransportError is expected after the header, got %T", err)
+	assert.EqualError(t, err, "handler panic: boom")
+}
+
+func TestClientEventStream(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": connected\n\n")
+		fmt.Fprint(w, "id: 1\nevent: greeting\ndata: hello\n\n")
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, "id: 2\ndata: multi\ndata: line\n\n")
+		w.(http.Flusher).Flush()
+	})
+
+	response, body := testy.New(handler).ExecuteStream(testy.MethodGet, "/events")
+	defer body.Close()
+	assert.Equal(t, "text/event-stream", response.GetHeader("Content-Type"))
+
+	events := testy.NewEventStream(body)
+	event, err := events.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, testy.Event{ID: "1", Event: "greeting", Data: "hello"}, event)
+
+	event, err = events.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, testy.Event{ID: "2", Data: "multi\nline"}, event)
+
+	_, err = events.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestClientDo(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"name": %q}`, r.Host)
+	})
+
+	request := httptest.NewRequest(http.MethodConnect, "/", nil)
+	request.Host = "proxy.example.com:443"
+
+	var user User
+	api := testy.New(handler).SetResult(&user).EnableHistory()
+	response := api.Do(request)
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, request, response.Request)
+	assert.NoError(t, response.Err)
+	assert.Equal(t, "proxy.example.com:443", user.Name)
+	assert.Len(t, api.History(), 1)
+}
+
+func TestClientSetCookie(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, "%s %d", cookie.Value, len(r.Cookies()))
+	})
+
+	response := testy.New(handler).Get("/")
+	assert.Equal(t, 401, response.StatusCode, "Unauthorized response is expected")
+
+	api := testy.New(handler).SetCookie(&http.Cookie{Name: "session", Value: "7f3c2a"})
+	response = api.Get("/")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "7f3c2a 1", response.String())
+
+	response = api.SetCookies([]*http.Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}}).Get("/")
+	assert.Equal(t, "7f3c2a 3", response.String(), "cookies are expected to accumulate")
+
+	response = api.Reset().Get("/")
+	assert.Equal(t, 401, response.StatusCode, "Reset is expected to clear cookies")
+}
+
+func TestClientBaseURL(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.String())
+	})
+
+	api := testy.New(handler).SetBaseURL("/api/v1")
+	assert.Equal(t, "/api/v1/users", api.Get("/users").String())
+	assert.Equal(t, "/api/v1/users", api.Get("users").String())
+	assert.Equal(t, "/api/v1", api.Get("").String())
+
+	api = testy.New(handler).SetBaseURL("/api/v1/")
+	assert.Equal(t, "/api/v1/users?page=2", api.SetQueryParam("page", "2").Get("/users").String(), "duplicate slash is expected to be dropped")
+
+	response := testy.New(handler).SetBaseURL("/api/v1").Get("http://example.com/health")
+	assert.Equal(t, "http://example.com/health", response.String(), "absolute URL is expected to bypass base URL")
+
+	response = testy.New(handler).SetBaseURL("/api/v1").Get("/login?next=http://x")
+	assert.Equal(t, "/api/v1/login?next=http://x", response.String(), "URL in the query is not expected to make it absolute")
+}
+
+func TestClientDefaultHeader(t *testing.T) {
+	handler := headerHandler("Accept")
+
+	api := testy.New(handler).SetDefaultHeader("Accept", "application/json")
+	assert.Equal(t, "application/json", api.Get("/").String())
+	assert.Equal(t, "text/plain", api.SetHeader("Accept", "text/plain").Get("/").String(), "SetHeader is expected to override default")
+
+	api.Reset()
+	assert.Equal(t, "application/json", api.Get("/").String(), "default is expected to survive Reset")
+	assert.Equal(t, "application/json", api.Clone().Get("/").String(), "default is expected to be cloned")
+	assert.Empty(t, api.Header, "default is not expected in the request state")
+}
+
+func TestClientDefaultContentType(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s", r.Header.Get("Content-Type"), body)
+	})
+
+	api := testy.New(handler).SetDefaultHeader("Content-Type", "application/xml").SetBody(Note{To: "bob"})
+	assert.Equal(t, "application/xml <note><to>bob</to><body></body></note>", api.Post("/").String(),
+		"default Content-Type is expected to choose XML")
+	assert.Empty(t, api.Header.Get("Content-Type"), "JSON Content-Type is not expected to be set")
+
+	api = testy.New(handler).SetDefaultHeader("Content-Type", "text/plain").SetBody("x").SetFormData(map[string]string{"a": "1"})
+	_, err := api.ExecuteE(testy.MethodPost, "/")
+	assert.EqualError(t, err, "ambiguous request body: both 'Body' and 'FormData' are set with explicit Content-Type")
+}
+
+func TestClientUserAgent(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.UserAgent())
+	})
+
+	assert.Equal(t, "testy/1.0", testy.New(handler).Get("/").String())
+	assert.Equal(t, "Mozilla/5.0", testy.New(handler).SetUserAgent("Mozilla/5.0").Get("/").String())
+	assert.Equal(t, "", testy.New(handler).SetUserAgent("").Get("/").String(), "empty User-Agent is expected to opt out")
+	assert.Equal(t, "curl/7.64", testy.New(handler).SetHeader("User-Agent", "curl/7.64").Get("/").String())
+}
+
+func TestClientBodyNDJSON(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		var names []string
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var user User
+			if err := json.Unmarshal(scanner.Bytes(), &user); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			names = append(names, user.Name)
+		}
+		fmt.Fprintf(w, "%d: %s", len(names), strings.Join(names, ","))
+	})
+
+	response := testy.New(handler).
+		SetBodyNDJSON(User{Name: "alice"}, User{Name: "bob"}, map[string]string{"name": "carol"}).
+		Post("/bulk")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "3: alice,bob,carol", response.String())
+}
+
+// flushCounter is a recorder counting Flush calls.
+type flushCounter struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (w *flushCounter) Flush() {
+	w.flushes++
+	w.ResponseRecorder.Flush()
+}
+
+func TestClientRecorderFactory(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, s := range []string{"a", "b", "c"} {
+			fmt.Fprint(w, s)
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	var recorders []*flushCounter
+	response := testy.New(handler).SetRecorderFactory(func() http.ResponseWriter {
+		w := &flushCounter{ResponseRecorder: httptest.NewRecorder()}
+		recorders = append(recorders, w)
+		return w
+	}).Get("/")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "abc", response.String())
+	assert.Len(t, recorders, 1)
+	assert.Equal(t, 3, recorders[0].flushes)
+}
+
+func TestClientChunks(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "a")
+		w.(http.Flusher).Flush()
+		io.WriteString(w, "b")
+		w.(http.Flusher).Flush()
+		if r.URL.Query().Get("tail") != "" {
+			io.WriteString(w, "c")
+		}
+	})
+
+	response := testy.New(handler).EnableChunks().Get("/")
+	assert.Equal(t, "ab", response.String())
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b")}, response.Chunks)
+
+	response = testy.New(handler).EnableChunks().SetQueryParam("tail", "1").Get("/")
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("c")}, response.Chunks, "unflushed tail is expected as last chunk")
+
+	response = testy.New(handler).Get("/")
+	assert.Nil(t, response.Chunks, "chunks are expected only when enabled")
+}
+
+type traceKey struct{}
+
+func TestClientContextValue(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace, ok := r.Context().Value(traceKey{}).(string)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, "%s %v", trace, r.Context().Value("user"))
+	})
+
+	response := testy.New(handler).Get("/")
+	assert.Equal(t, 401, response.StatusCode, "Unauthorized response is expected")
+
+	api := testy.New(handler).SetContextValue(traceKey{}, "span-1").SetContextValue("user", "bob")
+	response = api.Get("/")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "span-1 bob", response.String())
+
+	response = api.Reset().Get("/")
+	assert.Equal(t, 401, response.StatusCode, "Reset is expected to clear context values")
+}
+
+func TestClientAddFormData(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, strings.Join(r.PostForm["tag"], ","))
+	})
+
+	response := testy.New(handler).
+		AddFormData("tag", "http").
+		AddFormData("tag", "go").
+		SetFormDataFromValues(url.Values{"tag": {"test"}}).
+		Post("/")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "http,go,test", response.String(), "values are expected in insertion order")
+}
+
+func TestClientResultOnlyOnSuccess(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, "<html><body>Internal Server Error</body></html>")
+	})
+
+	var user User
+	response := testy.New(handler).SetResult(&user).Get("/")
+	assert.Equal(t, 500, response.StatusCode)
+	assert.NoError(t, response.Err, "error response is not expected to be decoded")
+	assert.Equal(t, "<html><body>Internal Server Error</body></html>", response.String())
+	assert.Equal(t, User{}, user)
+
+	response = testy.New(handler).SetResult(&user).SetResultOnAnyStatus(true).Get("/")
+	_, ok := response.Err.(*testy.DecodeError)
+	assert.True(t, ok, "*DecodeError is expected with SetResultOnAnyStatus, got %T", response.Err)
+}
+
+func TestClientTimeoutPanic(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	assert.PanicsWithValue(t, "boom", func() {
+		testy.New(handler).SetTimeout(time.Second).Get("/")
+	}, "handler panic is expected to be passed on")
+}
+
+func TestClientConditionalRequest(t *testing.T) {
+	modified := time.Date(2019, time.November, 5, 10, 30, 0, 0, time.UTC)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v42"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v42"`)
+		fmt.Fprint(w, "report")
+	})
+
+	response := testy.New(handler).Get("/")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+
+	response = testy.New(handler).SetIfNoneMatch("v42").Get("/")
+	assert.Equal(t, 304, response.StatusCode, "Not Modified response is expected")
+	assert.Equal(t, `"v42"`, response.Request.Header.Get("If-None-Match"))
+
+	response = testy.New(handler).SetIfNoneMatch(response.Request.Header.Get("If-None-Match")).Get("/")
+	assert.Equal(t, 304, response.StatusCode, "quoted entity tag is expected as is")
+
+	response = testy.New(handler).SetIfNoneMatch("v41").Get("/")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+
+	local := modified.In(time.FixedZone("CET", 3600))
+	response = testy.New(handler).SetIfModifiedSince(local).Get("/")
+	assert.Equal(t, 304, response.StatusCode, "Not Modified response is expected")
+	assert.Equal(t, "Tue, 05 Nov 2019 10:30:00 GMT", response.Request.Header.Get("If-Modified-Since"))
+
+	response = testy.New(handler).SetIfModifiedSince(modified.Add(-time.Hour)).Get("/")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+}
+
+func TestClientRange(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "digits.txt", time.Time{}, strings.NewReader("0123456789"))
+	})
+
+	response := testy.New(handler).SetRange(2, 5).Get("/")
+	assert.Equal(t, 206, response.StatusCode, "Partial Content response is expected")
+	assert.True(t, response.IsPartialContent())
+	assert.Equal(t, "bytes 2-5/10", response.GetHeader("Content-Range"))
+	assert.Equal(t, "2345", response.String())
+
+	response = testy.New(handler).SetRange(7, -1).Get("/")
+	assert.True(t, response.IsPartialContent())
+	assert.Equal(t, "bytes=7-", response.Request.Header.Get("Range"))
+	assert.Equal(t, "bytes 7-9/10", response.GetHeader("Content-Range"))
+	assert.Equal(t, "789", response.String())
+
+	response = testy.New(handler).Get("/")
+	assert.False(t, response.IsPartialContent())
+	assert.Equal(t, "0123456789", response.String())
+}
+
+func TestResponseFilename(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if disposition := r.URL.Query().Get("disposition"); disposition != "" {
+			w.Header().Set("Content-Disposition", disposition)
+		}
+		fmt.Fprint(w, "a,b,c")
+	})
+
+	response := testy.New(handler).SetQueryParam("disposition", `attachment; filename="report.csv"`).Get("/")
+	assert.Equal(t, "report.csv", response.Filename())
+
+	response = testy.New(handler).SetQueryParam("disposition", "inline").Get("/")
+	assert.Equal(t, "", response.Filename())
+
+	response = testy.New(handler).Get("/")
+	assert.Equal(t, "", response.Filename())
+}
+
+func TestNewFunc(t *testing.T) {
+	response := testy.NewFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "hello, %s!", r.URL.Query().Get("name"))
+	}).SetQueryParam("name", "bob").Get("/")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "hello, bob!", response.String())
+
+	assert.PanicsWithValue(t, "testy: handler is nil", func() { testy.NewFunc(nil) })
+}
+
+func TestRouter(t *testing.T) {
+	router := testy.NewRouter().
+		GET("/users", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "alice,bob")
+		}).
+		POST("/users", func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, "created %s", body)
+		})
+	api := testy.New(router)
+
+	response := api.Get("/users")
+	assert.Equal(t, 200, response.StatusCode, "OK response is expected")
+	assert.Equal(t, "alice,bob", response.String())
+
+	response = api.SetBody("carol").Post("/users")
+	assert.Equal(t, 201, response.StatusCode, "Created response is expected")
+	assert.Equal(t, "created carol", response.String())
+
+	response = api.Reset().Delete("/users")
+	assert.Equal(t, 405, response.StatusCode, "Method Not Allowed response is expected")
+	assert.Equal(t, "GET, POST", response.GetHeader("Allow"))
+
+	response = api.Get("/groups")
+	assert.Equal(t, 404, response.StatusCode, "Not Found response is expected")
+}
+
+func TestClientBodyBytesRead(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		io.CopyN(ioutil.Discard, r.Body, int64(n))
+	})
+
+	response := testy.New(handler).SetBody("0123456789").SetQueryParam("n", "5").Post("/")
+	assert.Equal(t, int64(10), response.RequestSize)
+	assert.Equal(t, int64(5), response.BodyBytesRead, "half of the body is expected to be read")
+
+	response = testy.New(handler).SetBody(strings.NewReader("0123456789")).SetQueryParam("n", "100").Post("/")
+	assert.Equal(t, int64(10), response.BodyBytesRead, "streamed body is expected to be counted")
+
+	response = testy.New(handler).Get("/")
+	assert.Equal(t, int64(0), response.BodyBytesRead)
+}
+
+func TestClientAcceptEncoding(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			fmt.Fprint(w, "plain")
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		fmt.Fprint(gz, "compressed")
+		gz.Close()
+	})
+
+	response := testy.New(handler).Get("/")
+	assert.Equal(t, "gzip", response.Request.Header.Get("Accept-Encoding"))
+	assert.Equal(t, "gzip", response.GetHeader("Content-Encoding"))
+	assert.Equal(t, "compressed", response.String(), "gzip body is expected to be decoded")
+
+	response = testy.New(handler).SetAcceptEncoding("br", "gzip").Get("/")
+	assert.Equal(t, "br, gzip", response.Request.Header.Get("Accept-Encoding"))
+	assert.Equal(t, "compressed", response.String())
+
+	response = testy.New(handler).SetAcceptEncoding().Get("/")
+	assert.Empty(t, response.Request.Header.Get("Accept-Encoding"))
+	assert.Equal(t, "plain", response.String())
+
+	response = testy.New(handler).SetHeader("Accept-Encoding", "identity").Get("/")
+	assert.Equal(t, "plain", response.String(), "SetHeader is expected to take precedence")
+}
+
+func TestClientExecuteFuzz(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil {
+			panic("no body")
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	})
+	api := testy.New(handler)
+
+	response, err := api.ExecuteFuzz(testy.MethodPost, "/", []byte("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", response.String())
+	assert.Nil(t, api.Body, "client is not expected to change")
+
+	response, err = api.ExecuteFuzz(testy.MethodPost, "/", nil)
+	assert.EqualError(t, err, "testy: panic: no body", "handler panic is expected as error")
+	assert.Nil(t, response)
+
+	_, err = api.ExecuteFuzz("BAD METHOD", "/", []byte("x"))
+	assert.EqualError(t, err, `net/http: invalid method "BAD METHOD"`)
+}
+
+func TestClientMount(t *testing.T) {
+	service := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "%s %s", name, r.URL.Path)
+		})
+	}
+
+	api := testy.New(service("primary")).
+		Mount("/auth", service("auth")).
+		Mount("/api", service("api")).
+		Mount("/api/v2", service("api-v2"))
+
+	assert.Equal(t, "auth /auth/token", api.Get("/auth/token").String())
+	assert.Equal(t, "api /api/users", api.Get("/api/users").String())
+	assert.Equal(t, "api /api", api.Get("/api").String())
+	assert.Equal(t, "api-v2 /api/v2/users", api.Get("/api/v2/users").String(), "longest prefix is expected to win")
+	assert.Equal(t, "primary /authors", api.Get("/authors").String(), "prefix is expected to match whole segments")
+	assert.Equal(t, "primary /health", api.Get("/health").String())
+
+	api.Mount("/", service("root"))
+	assert.Equal(t, "root /health", api.Get("/health").String(), "root prefix is expected to match any path")
+	assert.Equal(t, "auth /auth/token", api.Get("/auth/token").String(), "longer prefix is expected to win over root")
+}
+
+func TestClientFailBeforeHandler(t *testing.T) {
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+	api := testy.New(handler).SetFailBeforeHandler(io.ErrUnexpectedEOF)
+
+	response, err := api.ExecuteE(testy.MethodGet, "/")
+	assert.Nil(t, response)
+	transportErr, ok := err.(*testy.TransportError)
+	assert.True(t, ok, "*TransportError is expected, got %T", err)
+	assert.Equal(t, io.ErrUnexpectedEOF, transportErr.Err)
+	assert.Panics(t, func() { api.Get("/") }, "Execute is expected to panic")
+	assert.Equal(t, 0, calls, "handler is not expected to be called")
+
+	api.SetFailBeforeHandler(nil).Get("/")
+	assert.Equal(t, 1, calls)
+}
+
+func TestClientChunked(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%v %d %s", r.TransferEncoding, r.ContentLength, body)
+	})
+
+	api := testy.New(handler).SetBody("payload")
+	assert.Equal(t, "[] 7 payload", api.Post("/").String())
+
+	assert.Equal(t, "[chunked] -1 payload", api.SetChunked(true).Post("/").String())
+
+	api.Reset().SetBody("x")
+	assert.Equal(t, "[] 1 x", api.Post("/").String(), "Reset is expected to clear chunked")
+}
+
+func TestClientSigner(t *testing.T) {
+	key := []byte("test-key")
+	sign := func(method, path string, body []byte) string {
+		mac := hmac.New(sha256.New, key)
+		fmt.Fprintf(mac, "%s\n%s\n", method, path)
+		mac.Write(body)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !hmac.Equal([]byte(r.Header.Get("X-Signature")), []byte(sign(r.Method, r.URL.RequestURI(), body))) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(body)
+	})
+	signer := func(r *http.Request, body []byte) {
+		r.Header.Set("X-Signature", sign(r.Method, r.URL.RequestURI(), body))
+	}
+
+	response := testy.New(handler).SetBody("payload").Post("/")
+	assert.Equal(t, 401, response.StatusCode, "unsigned request is expected to be rejected")
+
+	response = testy.New(handler).SetSigner(signer).
+		SetPathParam("id", "42").
+		SetQueryParam("page", "2").
+		SetBody(`{"name": "bob"}`).
+		Post("/users/{id}")
+	assert.Equal(t, 200, response.StatusCode, "signed request is expected to be accepted")
+	assert.Equal(t, `{"name": "bob"}`, response.String())
+
+	response = testy.New(handler).SetSigner(signer).
+		SetFormData(map[string]string{"name": "alice"}).
+		Post("/form")
+	assert.Equal(t, 200, response.StatusCode, "encoded form is expected to be signed")
+
+	response = testy.New(handler).SetSigner(signer).
+		SetBody(strings.NewReader("streamed")).
+		Post("/")
+	assert.Equal(t, 200, response.StatusCode, "streamed body is expected to be signed")
+	assert.Equal(t, "streamed", response.String())
+}
+
+func TestClientGzipEmptyBody(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.WriteHeader(http.StatusNotModified)
+	})
+
+	response, err := testy.New(handler).ExecuteE(testy.MethodHead, "/")
+	assert.NoError(t, err, "empty gzip body is not expected to be decoded")
+	assert.Equal(t, 200, response.StatusCode)
+
+	response, err = testy.New(handler).ExecuteE(testy.MethodGet, "/")
+	assert.NoError(t, err, "empty gzip body is not expected to be decoded")
+	assert.Equal(t, 304, response.StatusCode)
+	assert.True(t, response.IsEmpty())
+}
+
+func TestClientJSONDecoderTrailingData(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"name":"bob"} `+r.URL.Query().Get("trailing"))
+	})
+
+	var user User
+	response := testy.New(handler).SetResult(&user).SetQueryParam("trailing", "garbage").Get("/")
+	assert.Error(t, response.Err, "trailing data is expected to fail by default")
+
+	for _, trailing := range []string{"garbage", `{"name":"alice"}`} {
+		response = testy.New(handler).SetJSONDecoderOptions(true, false).SetResult(&user).SetQueryParam("trailing", trailing).Get("/")
+		assert.EqualError(t, response.Err, "json: unexpected data after top-level value", "trailing %q is expected to fail", trailing)
+	}
+
+	response = testy.New(handler).SetJSONDecoderOptions(true, true).SetResult(&user).Get("/")
+	assert.NoError(t, response.Err, "trailing whitespace is expected to be accepted")
+	assert.Equal(t, "bob", user.Name)
+}
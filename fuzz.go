@@ -0,0 +1,35 @@
+package testy
+
+import "fmt"
+
+// ExecuteFuzz method runs the request with the raw body on top of the client request state,
+// without changing the client, like ExecuteWith, and returns any panic, including the handler's,
+// as an error, so a fuzz target stays alive on malformed input. Nil body sends no body.
+//
+// 		func FuzzUser(f *testing.F) {
+// 			client := testy.New(handler)
+// 			f.Fuzz(func(t *testing.T, body []byte) {
+// 				if response, err := client.ExecuteFuzz(testy.MethodPost, "/user", body); err == nil {
+// 					response.AssertSuccess(t)
+// 				}
+// 			})
+// 		}
+func (c *Client) ExecuteFuzz(method, url string, body []byte) (response *Response, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			response = nil
+			if e, ok := p.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("testy: panic: %v", p)
+			}
+		}
+	}()
+
+	rc := c.Clone()
+	rc.recordHistory = false
+	if body != nil {
+		rc.SetBody(body)
+	}
+	return rc.ExecuteE(method, url)
+}
@@ -1,10 +1,11 @@
 module github.com/miketonks/testy
 
-go 1.12
+go 1.14
 
 require (
 	github.com/labstack/echo v3.3.10+incompatible
 	github.com/labstack/gommon v0.3.0 // indirect
 	github.com/stretchr/testify v1.4.0
+	github.com/xeipuuv/gojsonschema v1.2.0
 	golang.org/x/crypto v0.0.0-20191011191535-87dc89f01550 // indirect
 )
@@ -0,0 +1,43 @@
+package testy
+
+// RequestOption configures a single request run by ExecuteWith.
+type RequestOption func(*Client)
+
+// WithHeader option sets the header field for the request only.
+func WithHeader(header, value string) RequestOption {
+	return func(c *Client) {
+		c.SetHeader(header, value)
+	}
+}
+
+// WithQueryParam option sets the query parameter for the request only.
+func WithQueryParam(param, value string) RequestOption {
+	return func(c *Client) {
+		c.SetQueryParam(param, value)
+	}
+}
+
+// WithBody option sets the body for the request only, the same way as SetBody.
+func WithBody(body interface{}) RequestOption {
+	return func(c *Client) {
+		c.SetBody(body)
+	}
+}
+
+// ExecuteWith method runs the request with options applied on top of the client
+// request state, without changing the client, e.g. for one-off headers.
+//
+// 		client.ExecuteWith(testy.MethodGet, "/hello", testy.WithHeader("X-UserName", "bob"))
+func (c *Client) ExecuteWith(method, url string, opts ...RequestOption) *Response {
+	rc := c.Clone()
+	rc.recordHistory = false
+	for _, opt := range opts {
+		opt(rc)
+	}
+
+	response := rc.Execute(method, url)
+	if c.recordHistory {
+		c.history = append(c.history, response)
+	}
+	return response
+}
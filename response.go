@@ -0,0 +1,153 @@
+package testy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+)
+
+// JSONMap method unmarshals the JSON object response body into a map.
+func (r *Response) JSONMap() (map[string]interface{}, error) {
+	var m map[string]interface{}
+	if err := r.JSON(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// JSONSlice method unmarshals the JSON array response body into a slice.
+func (r *Response) JSONSlice() ([]interface{}, error) {
+	var s []interface{}
+	if err := r.JSON(&s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Header method returns the response headers.
+func (r *Response) Header() http.Header {
+	return r.RawResponse.Header
+}
+
+// GetHeader method returns the first value of the given response header, or empty string if absent.
+//
+// 		assert.Equal(t, "application/json", response.GetHeader("Content-Type"))
+func (r *Response) GetHeader(key string) string {
+	return r.Header().Get(key)
+}
+
+// JSON method unmarshals the response body into v, returning any decoding error
+// rather than panicking. It works independently of SetResult.
+//
+// 		var user User
+// 		if err := response.JSON(&user); err != nil {
+// 			t.Fatal(err)
+// 		}
+func (r *Response) JSON(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Trailer method returns the response trailers, populated once the body was read by Execute.
+func (r *Response) Trailer() http.Header {
+	return r.RawResponse.Trailer
+}
+
+// Cookies method returns all cookies set by the response.
+func (r *Response) Cookies() []*http.Cookie {
+	return r.RawResponse.Cookies()
+}
+
+// Cookie method returns the named cookie set by the response, and whether it was found.
+//
+// 		session, ok := response.Cookie("session")
+func (r *Response) Cookie(name string) (*http.Cookie, bool) {
+	for _, cookie := range r.Cookies() {
+		if cookie.Name == name {
+			return cookie, true
+		}
+	}
+	return nil, false
+}
+
+// PrettyString method returns the response body indented, if it's valid JSON,
+// otherwise the same as String. It's handy for printing on test failure.
+func (r *Response) PrettyString() string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, r.Body, "", "  "); err != nil {
+		return r.String()
+	}
+	return buf.String()
+}
+
+// IsJSON method reports whether the response body is valid JSON. Empty body is not.
+func (r *Response) IsJSON() bool {
+	return json.Valid(r.Body)
+}
+
+// Filename method returns the filename parameter of the Content-Disposition header,
+// e.g. the suggested name of a download, empty if absent.
+func (r *Response) Filename() string {
+	_, params, err := mime.ParseMediaType(r.GetHeader("Content-Disposition"))
+	if err != nil {
+		return ""
+	}
+	return params["filename"]
+}
+
+// DumpRequest method returns the request in its HTTP/1.x wire representation, e.g. to share
+// a repro. The body is included when it can be replayed, i.e. it wasn't streamed.
+func (r *Response) DumpRequest() (string, error) {
+	request := r.Request.Clone(r.Request.Context())
+	request.Body = nil
+	if r.Request.GetBody != nil {
+		body, err := r.Request.GetBody()
+		if err != nil {
+			return "", err
+		}
+		request.Body = body
+	}
+	dump, err := httputil.DumpRequest(request, request.Body != nil)
+	return string(dump), err
+}
+
+// DumpResponse method returns the response in its HTTP/1.x wire representation, including the body.
+func (r *Response) DumpResponse() (string, error) {
+	dump, err := httputil.DumpResponse(r.RawResponse, true)
+	return string(dump), err
+}
+
+// IsSuccess method reports whether the response status code is 2xx.
+func (r *Response) IsSuccess() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// IsError method reports whether the response status code is 4xx or 5xx.
+func (r *Response) IsError() bool {
+	return r.StatusCode >= 400 && r.StatusCode < 600
+}
+
+// IsPartialContent method reports whether the response is 206 Partial Content, see SetRange.
+func (r *Response) IsPartialContent() bool {
+	return r.StatusCode == http.StatusPartialContent
+}
+
+// IsEmpty method reports whether the response has no body, e.g. 204 No Content.
+func (r *Response) IsEmpty() bool {
+	return len(r.Body) == 0
+}
+
+// SaveToFile method writes the response body to the file, creating parent directories
+// as needed, e.g. to generate golden files.
+//
+// 		client.Get("/report").SaveToFile("testdata/report.golden")
+func (r *Response) SaveToFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, r.Body, 0644)
+}
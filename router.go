@@ -0,0 +1,62 @@
+package testy
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Router is a minimal http.Handler routing requests by method and exact path, for quick tests
+// that don't need a web framework. Unknown paths get 404, known paths with another method 405.
+//
+// 		router := testy.NewRouter().
+// 			GET("/users", listUsers).
+// 			POST("/users", createUser)
+// 		client := testy.New(router)
+type Router struct {
+	routes map[string]map[string]http.HandlerFunc
+}
+
+// NewRouter method creates an empty router.
+func NewRouter() *Router {
+	return &Router{routes: map[string]map[string]http.HandlerFunc{}}
+}
+
+// Handle method registers the handler function for the method and path.
+func (rt *Router) Handle(method, path string, fn http.HandlerFunc) *Router {
+	if rt.routes[path] == nil {
+		rt.routes[path] = map[string]http.HandlerFunc{}
+	}
+	rt.routes[path][method] = fn
+	return rt
+}
+
+// GET method registers the handler function for GET requests of the path.
+func (rt *Router) GET(path string, fn http.HandlerFunc) *Router {
+	return rt.Handle(http.MethodGet, path, fn)
+}
+
+// POST method registers the handler function for POST requests of the path.
+func (rt *Router) POST(path string, fn http.HandlerFunc) *Router {
+	return rt.Handle(http.MethodPost, path, fn)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	methods, ok := rt.routes[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	fn, ok := methods[r.Method]
+	if !ok {
+		allowed := make([]string, 0, len(methods))
+		for method := range methods {
+			allowed = append(allowed, method)
+		}
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	fn(w, r)
+}
@@ -0,0 +1,78 @@
+package testy
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Event is a server-sent event read from a text/event-stream body.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// EventStream reads server-sent events from a text/event-stream body.
+type EventStream struct {
+	scanner *bufio.Scanner
+}
+
+// NewEventStream creates EventStream reading from r, usually the body returned by ExecuteStream.
+//
+// 		_, body := client.ExecuteStream(testy.MethodGet, "/events")
+// 		defer body.Close()
+// 		events := testy.NewEventStream(body)
+// 		for {
+// 			event, err := events.Next()
+// 			if err == io.EOF {
+// 				break
+// 			}
+// 			...
+// 		}
+func NewEventStream(r io.Reader) *EventStream {
+	return &EventStream{scanner: bufio.NewScanner(r)}
+}
+
+// Next method returns the next event, or io.EOF when the stream ended.
+// Comments and unknown fields are skipped, multiple data lines are joined with newline.
+func (s *EventStream) Next() (Event, error) {
+	var event Event
+	var data []string
+	pending := false
+
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		if line == "" {
+			if !pending {
+				continue
+			}
+			event.Data = strings.Join(data, "\n")
+			return event, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if idx := strings.Index(line, ":"); idx >= 0 {
+			field, value = line[:idx], strings.TrimPrefix(line[idx+1:], " ")
+		}
+		switch field {
+		case "id":
+			event.ID = value
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+		default:
+			continue
+		}
+		pending = true
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
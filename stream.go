@@ -0,0 +1,119 @@
+package testy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ExecuteStream method runs the request against the handler in the background, returning
+// as soon as the handler writes the header, along with the response body reader, which isn't
+// drained, e.g. to read events of a long-poll handler as they come. Response.Body is empty,
+// and the response hooks aren't run. The caller must close the reader.
+// A handler panic before the header is written is passed on, afterwards the reader
+// fails with *TransportError.
+//
+// 		response, body := client.ExecuteStream(testy.MethodGet, "/events")
+// 		defer body.Close()
+// 		scanner := bufio.NewScanner(body)
+// 		for scanner.Scan() {
+// 			...
+// 		}
+func (c *Client) ExecuteStream(method, url string) (*Response, io.ReadCloser) {
+	request, _, requestSize, err := c.prepareRequest(method, url)
+	if err != nil {
+		panic(err)
+	}
+	for _, fn := range c.beforeRequest {
+		fn(request)
+	}
+	if c.signer != nil {
+		body, err := replayBody(request)
+		if err != nil {
+			panic(&TransportError{err})
+		}
+		c.signer(request, body)
+	}
+
+	pr, pw := io.Pipe()
+	w := &streamWriter{
+		header: http.Header{},
+		body:   pw,
+		ready:  make(chan struct{}),
+	}
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				if w.code == 0 {
+					// the caller is still waiting for the header, pass the panic on
+					panicked <- p
+				}
+				pw.CloseWithError(&TransportError{fmt.Errorf("handler panic: %v", p)})
+				return
+			}
+			pw.Close()
+		}()
+		c.handle(w, request)
+		w.WriteHeader(http.StatusOK)
+		if closer, ok := c.BodyReader.(io.Closer); ok {
+			closer.Close()
+		}
+	}()
+	select {
+	case <-w.ready:
+	case p := <-panicked:
+		panic(p)
+	}
+
+	result := &http.Response{
+		Status:     fmt.Sprintf("%03d %s", w.code, http.StatusText(w.code)),
+		StatusCode: w.code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     w.sent,
+		Body:       pr,
+		Request:    request,
+	}
+	return &Response{
+		Request:     request,
+		RawResponse: result,
+		RequestSize: requestSize,
+		Status:      result.Status,
+		StatusCode:  result.StatusCode,
+	}, pr
+}
+
+// streamWriter is http.ResponseWriter passing the body through a pipe as it's written.
+type streamWriter struct {
+	header http.Header
+	sent   http.Header
+	code   int
+	body   *io.PipeWriter
+	ready  chan struct{}
+	once   sync.Once
+}
+
+func (w *streamWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *streamWriter) WriteHeader(code int) {
+	w.once.Do(func() {
+		w.code = code
+		w.sent = w.header.Clone()
+		close(w.ready)
+	})
+}
+
+func (w *streamWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// Flush sends the header, the body is written through unbuffered.
+func (w *streamWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
@@ -2,17 +2,37 @@ package testy
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Method is an HTTP method, see ExecuteMethod. The Method constants are untyped,
+// so they can be passed to both ExecuteMethod and the string based Execute.
+type Method string
+
 const (
 	// MethodGet HTTP method
 	MethodGet = "GET"
@@ -36,34 +56,148 @@ const (
 	MethodOptions = "OPTIONS"
 )
 
+const (
+	headerContentType     = "Content-Type"
+	headerAuthorization   = "Authorization"
+	headerContentEncoding = "Content-Encoding"
+	headerUserAgent       = "User-Agent"
+	headerAcceptEncoding  = "Accept-Encoding"
+
+	defaultUserAgent = "testy/1.0"
+
+	formContentType = "application/x-www-form-urlencoded"
+	jsonContentType = "application/json"
+	xmlContentType  = "application/xml"
+	ndjsonType      = "application/x-ndjson"
+)
+
 // Client ...
 type Client struct {
 	handler    http.Handler
+	mounts     []mount
+	baseURL    string
 	QueryParam url.Values
+	RawQuery   string
+	noEscape   map[string]bool
+	PathParams map[string]string
 	FormData   url.Values
 	Header     http.Header
+	Cookies    []*http.Cookie
 	Body       []byte
+	BodyReader io.Reader
+	Files      []*File
+	Boundary   string
 	Result     interface{}
 	Error      interface{}
+	ctx        context.Context
+	jar        http.CookieJar
+	tls        bool
+	remoteAddr string
+	host       string
+
+	defaultHeader  http.Header
+	userAgent      string
+	acceptEncoding []string
+
+	contentLength    int64
+	setContentLength bool
+	chunked          bool
+	maxBodySize      int64
+	maxResponseSize  int64
+
+	followRedirects bool
+	maxRedirects    int
+	compress        bool
+	retryCount      int
+	retryOn         []int
+
+	newRecorder func() http.ResponseWriter
+	timeout     time.Duration
+	latency     time.Duration
+
+	failBeforeHandler error
+
+	beforeRequest []func(*http.Request)
+	signer        func(*http.Request, []byte)
+	afterResponse []func(*Response)
+
+	recordHistory bool
+	history       []*Response
+
+	debug  bool
+	logger io.Writer
+
+	lastErr error
+	t       FatalT
+
+	disallowUnknownFields bool
+	useNumber             bool
+	resultOnAnyStatus     bool
+	jsonMarshal           func(interface{}) ([]byte, error)
+	jsonUnmarshal         func([]byte, interface{}) error
+}
+
+// mount is a handler mounted on a path prefix, see Mount.
+type mount struct {
+	prefix  string
+	handler http.Handler
+}
+
+// File represents a file part of a multipart/form-data request.
+type File struct {
+	Name      string
+	ParamName string
+	io.Reader
 }
 
 // Response ...
 type Response struct {
-	RawResponse *http.Response
-	Body        []byte
-	Status      string
-	StatusCode  int
-	Size        int64
+	Request       *http.Request
+	RawResponse   *http.Response
+	Body          []byte
+	Status        string
+	StatusCode    int
+	Size          int64
+	RequestSize   int64
+	BodyBytesRead int64
+	Redirects     []string
+	Chunks        [][]byte
+	Duration      time.Duration
+	Truncated     bool
+	Err           error
 }
 
-// New ...
+// New method creates a client for the handler. It panics if the handler is nil.
 func New(h http.Handler) *Client {
+	if h == nil {
+		panic("testy: handler is nil")
+	}
 	return &Client{
 		handler:    h,
 		QueryParam: url.Values{},
+		PathParams: map[string]string{},
 		FormData:   url.Values{},
 		Header:     http.Header{},
+		ctx:        context.Background(),
+
+		maxRedirects:   10,
+		userAgent:      defaultUserAgent,
+		acceptEncoding: []string{"gzip"},
+		logger:         os.Stderr,
+	}
+}
+
+// NewFunc method creates a client for the handler function, the same as New(http.HandlerFunc(fn)).
+// It panics if the function is nil.
+//
+// 		client := testy.NewFunc(func(w http.ResponseWriter, r *http.Request) {
+// 			fmt.Fprint(w, "hello")
+// 		})
+func NewFunc(fn func(http.ResponseWriter, *http.Request)) *Client {
+	if fn == nil {
+		panic("testy: handler is nil")
 	}
+	return New(http.HandlerFunc(fn))
 }
 
 // Get ...
@@ -81,202 +215,1762 @@ func (c *Client) Post(url string) *Response {
 	return c.Execute("POST", url)
 }
 
+// Put ...
+func (c *Client) Put(url string) *Response {
+	return c.Execute(MethodPut, url)
+}
+
 // Delete ...
 func (c *Client) Delete(url string) *Response {
 	return c.Execute("DELETE", url)
 }
 
-// Execute ...
+// Head ...
+func (c *Client) Head(url string) *Response {
+	return c.Execute(MethodHead, url)
+}
+
+// Options ...
+func (c *Client) Options(url string) *Response {
+	return c.Execute(MethodOptions, url)
+}
+
+// Execute method runs the request against the handler, using the current request state.
+// It does not reset that state afterwards, so use Reset when reusing the client.
+// RawResponse.Body can be read again after Execute, with the body as it was sent by the handler.
+// Gzip encoded response body is decompressed, the compressed bytes remain in RawResponse.Body.
+// The request is retried as configured by SetRetry, returning the last response
+// after running the OnAfterResponse hooks.
+//
+// It panics if the request can't be built, with *MarshalError or *TransportError, see ExecuteE.
+// Errors decoding the response are available from Response.Err, as *DecodeError.
 func (c *Client) Execute(method, url string) *Response {
+	if c.t != nil {
+		defer c.recoverT(method + " " + url)
+	}
+	response, err := c.ExecuteE(method, url)
+	if response == nil {
+		panic(err)
+	}
+	return response
+}
 
-	if len(c.QueryParam) > 0 {
-		url = fmt.Sprintf("%s?%s", url, c.QueryParam.Encode())
+// ExecuteMethod method runs the request the same way as Execute, but it panics with *TransportError
+// if the method isn't one of Method constants, catching typos like "GTE" early.
+//
+// 		response := client.ExecuteMethod(testy.MethodGet, "/hello")
+func (c *Client) ExecuteMethod(m Method, url string) *Response {
+	if c.t != nil {
+		defer c.recoverT(string(m) + " " + url)
 	}
+	switch m {
+	case MethodGet, MethodPost, MethodPut, MethodDelete, MethodPatch, MethodHead, MethodOptions:
+		return c.Execute(string(m), url)
+	}
+	panic(&TransportError{fmt.Errorf("unknown method %q", m)})
+}
 
-	var reader io.Reader
-	if c.Body != nil {
-		reader = bytes.NewReader(c.Body)
+// ExecuteE method runs the request the same way as Execute, but returns errors building
+// the request, reading and decoding the response instead of panicking. When the response
+// is available, e.g. for decoding errors, it's returned along with the error.
+//
+// 		response, err := client.ExecuteE(testy.MethodGet, "/hello")
+func (c *Client) ExecuteE(method, url string) (*Response, error) {
+	rewind := func() {}
+	if c.retryCount > 0 {
+		var err error
+		if rewind, err = c.bufferBodies(); err != nil {
+			return nil, &MarshalError{err}
+		}
+		rewind()
 	}
-	request, _ := http.NewRequest(method, url, reader)
-	request.Header = c.Header
 
-	recorder := httptest.NewRecorder()
-	c.handler.ServeHTTP(recorder, request)
+	response, err := c.execute(method, url)
+	for attempt := 0; response != nil && attempt < c.retryCount && c.shouldRetry(response.StatusCode); attempt++ {
+		rewind()
+		response, err = c.execute(method, url)
+	}
 
-	result := recorder.Result()
-	response := Response{
-		RawResponse: result,
-		Status:      result.Status,
-		StatusCode:  result.StatusCode,
+	if response != nil {
+		c.completed(response)
 	}
+	return response, err
+}
 
-	var err error
-	if response.Body, err = ioutil.ReadAll(result.Body); err != nil {
+// Do method runs the prebuilt request against the handler, e.g. for an unusual method,
+// and builds the response the same way as Execute, including Result and Error decoding
+// and the OnAfterResponse hooks. The request state of the client isn't used, cookie jar
+// cookies aren't added and the request isn't retried, since its body can't be replayed.
+// It panics if the response can't be read.
+//
+// 		request := httptest.NewRequest(http.MethodConnect, "/tunnel", nil)
+// 		response := client.Do(request)
+func (c *Client) Do(req *http.Request) *Response {
+	var size int64
+	if req.ContentLength > 0 {
+		size = req.ContentLength
+	}
+	response, err := c.run(req, req.Header.Clone(), size)
+	if response == nil {
 		panic(err)
 	}
+	c.completed(response)
+	return response
+}
 
-	response.Size = int64(len(response.Body))
+// completed runs the OnAfterResponse hooks and records the response history.
+func (c *Client) completed(response *Response) {
+	for _, fn := range c.afterResponse {
+		fn(response)
+	}
+	if c.recordHistory {
+		c.history = append(c.history, response)
+	}
+}
 
-	if c.Result != nil {
-		err = json.Unmarshal(response.Body, c.Result)
-		if err != nil {
-			panic(err)
+// ExecuteConcurrent method runs n copies of the request concurrently, e.g. to shake out
+// data races with -race flag, and returns all responses in no particular order.
+// Each request runs on a snapshot of the request state, with its own copy of the streamed
+// body and files, which are read into memory first. Result and Error are not
+// populated, since the shared targets would race, use Response.JSON instead.
+// The OnAfterResponse hooks and history recording run on the client once all requests are done.
+// A panic in any of the requests is passed on to the caller afterwards, or reported through WithT.
+//
+// 		responses := client.ExecuteConcurrent(50, testy.MethodGet, "/hello")
+func (c *Client) ExecuteConcurrent(n int, method, url string) []*Response {
+	if c.t != nil {
+		defer c.recoverT(method + " " + url)
+	}
+	rewind, err := c.bufferBodies()
+	if err != nil {
+		panic(&MarshalError{err})
+	}
+
+	responses := make([]*Response, n)
+	panics := make([]interface{}, n)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		rewind()
+		snapshot := c.Clone()
+		snapshot.Result = nil
+		snapshot.Error = nil
+		snapshot.afterResponse = nil
+		snapshot.recordHistory = false
+		// t.Fatalf must be called from the test goroutine, the panics are reported below
+		snapshot.t = nil
+		go func(i int) {
+			defer wg.Done()
+			defer func() {
+				panics[i] = recover()
+			}()
+			responses[i] = snapshot.Execute(method, url)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, response := range responses {
+		if response != nil {
+			c.completed(response)
+		}
+	}
+	for _, p := range panics {
+		if p != nil {
+			panic(p)
 		}
 	}
-	return &response
+	return responses
 }
 
-// SetHeader method is to set a single header field and its value in the current request.
-//
-// For Example: To set `Content-Type` and `Accept` as `application/json`.
-// 		client.R().
-//			SetHeader("Content-Type", "application/json").
-//			SetHeader("Accept", "application/json")
-//
-// Also you can override header value, which was set at client instance level.
-func (c *Client) SetHeader(header, value string) *Client {
-	c.Header.Set(header, value)
-	return c
+// bufferBodies reads the streamed body and files into memory, so they can be sent again,
+// returning the function rewinding them for the next attempt. Readers are closed once read.
+func (c *Client) bufferBodies() (func(), error) {
+	readAll := func(r io.Reader) ([]byte, error) {
+		if closer, ok := r.(io.Closer); ok {
+			defer closer.Close()
+		}
+		return ioutil.ReadAll(r)
+	}
+
+	var body []byte
+	streamed := c.BodyReader != nil
+	if streamed {
+		var err error
+		if body, err = readAll(c.BodyReader); err != nil {
+			return nil, err
+		}
+	}
+	files := make([][]byte, len(c.Files))
+	for i, f := range c.Files {
+		var err error
+		if files[i], err = readAll(f.Reader); err != nil {
+			return nil, err
+		}
+	}
+
+	source := c.Files
+	return func() {
+		if streamed {
+			c.BodyReader = bytes.NewReader(body)
+		}
+		c.Files = make([]*File, len(source))
+		for i, f := range source {
+			c.Files[i] = &File{Name: f.Name, ParamName: f.ParamName, Reader: bytes.NewReader(files[i])}
+		}
+	}, nil
 }
 
-// SetHeaders method sets multiple headers field and its values at one go in the current request.
-//
-// For Example: To set `Content-Type` and `Accept` as `application/json`
-//
-// 		client.R().
-//			SetHeaders(map[string]string{
-//				"Content-Type": "application/json",
-//				"Accept": "application/json",
-//			})
-// Also you can override header value, which was set at client instance level.
-func (c *Client) SetHeaders(headers map[string]string) *Client {
-	for h, v := range headers {
-		c.SetHeader(h, v)
+func (c *Client) shouldRetry(code int) bool {
+	if len(c.retryOn) == 0 {
+		return code >= http.StatusInternalServerError
 	}
-	return c
+	for _, retryCode := range c.retryOn {
+		if code == retryCode {
+			return true
+		}
+	}
+	return false
 }
 
-// SetQueryParam method sets single parameter and its value in the current request.
-// It will be formed as query string for the request.
-//
-// For Example: `search=kitchen%20papers&size=large` in the URL after `?` mark.
-// 		client.R().
-//			SetQueryParam("search", "kitchen papers").
-//			SetQueryParam("size", "large")
-// Also you can override query params value, which was set at client instance level.
-func (c *Client) SetQueryParam(param, value string) *Client {
-	c.QueryParam.Set(param, value)
-	return c
+func (c *Client) execute(method, url string) (*Response, error) {
+	request, header, requestSize, err := c.prepareRequest(method, url)
+	if err != nil {
+		return nil, err
+	}
+	return c.run(request, header, requestSize)
 }
 
-// SetQueryParams method sets multiple parameters and its values at one go in the current request.
-// It will be formed as query string for the request.
-//
-// For Example: `search=kitchen%20papers&size=large` in the URL after `?` mark.
-// 		client.R().
-//			SetQueryParams(map[string]string{
-//				"search": "kitchen papers",
-//				"size": "large",
-//			})
-// Also you can override query params value, which was set at client instance level.
-func (c *Client) SetQueryParams(params map[string]string) *Client {
-	for p, v := range params {
-		c.SetQueryParam(p, v)
+// run serves the request, following redirects, and reads and decodes the response.
+func (c *Client) run(request *http.Request, header http.Header, requestSize int64) (*Response, error) {
+	if c.failBeforeHandler != nil {
+		return nil, &TransportError{c.failBeforeHandler}
 	}
-	return c
+	start := time.Now()
+	out := c.serve(request)
+
+	var redirects []string
+	var redirectErr error
+	for out.err == nil && c.followRedirects && isRedirect(out.result.StatusCode) {
+		if len(redirects) == c.maxRedirects {
+			redirectErr = &TransportError{fmt.Errorf("stopped after %d redirects", c.maxRedirects)}
+			break
+		}
+		next := c.redirectRequest(request, out.result, header)
+		if next == nil {
+			break
+		}
+		redirects = append(redirects, next.URL.String())
+		request = next
+		out = c.serve(next)
+	}
+	result := out.result
+
+	response := Response{
+		Request:       request,
+		RawResponse:   result,
+		RequestSize:   requestSize,
+		BodyBytesRead: out.bodyRead,
+		Status:        result.Status,
+		StatusCode:    result.StatusCode,
+		Redirects:     redirects,
+		Chunks:        out.chunks,
+		Duration:      time.Since(start),
+	}
+
+	var body io.Reader = result.Body
+	if c.maxResponseSize > 0 {
+		body = io.LimitReader(result.Body, c.maxResponseSize+1)
+	}
+
+	var err error
+	if response.Body, err = ioutil.ReadAll(body); err != nil {
+		return nil, &TransportError{err}
+	}
+	if c.maxResponseSize > 0 && int64(len(response.Body)) > c.maxResponseSize {
+		response.Body = response.Body[:c.maxResponseSize]
+		response.Truncated = true
+	}
+
+	// keep the body as read, i.e. still compressed, readable from RawResponse
+	result.Body = ioutil.NopCloser(bytes.NewReader(response.Body))
+
+	var decodeErr error
+	// e.g. HEAD or 304 response declares the encoding of the body it doesn't have
+	if result.Header.Get(headerContentEncoding) == "gzip" && len(response.Body) > 0 {
+		if decoded, err := gunzip(response.Body); err != nil {
+			decodeErr = &DecodeError{err}
+		} else {
+			response.Body = decoded
+		}
+	}
+
+	response.Size = int64(len(response.Body))
+	if response.Size == 0 && result.ContentLength > 0 {
+		// e.g. HEAD response declares the length of the body it doesn't have
+		response.Size = result.ContentLength
+	}
+
+	if out.err != nil {
+		response.Err = out.err
+	} else if redirectErr != nil {
+		response.Err = redirectErr
+	} else if decodeErr != nil {
+		response.Err = decodeErr
+	} else if response.StatusCode >= http.StatusBadRequest && c.Error != nil {
+		if err := c.decodeJSON(response.Body, c.Error); err != nil {
+			response.Err = &DecodeError{err}
+		}
+	} else if c.Result != nil && (response.IsSuccess() || c.resultOnAnyStatus) {
+		if err := c.decodeJSON(response.Body, c.Result); err != nil {
+			response.Err = &DecodeError{err}
+		}
+	}
+
+	if c.debug {
+		c.debugLog(request, &response)
+	}
+	return &response, response.Err
 }
 
-// SetQueryParamsFromValues method appends multiple parameters with multi-value
-// (`url.Values`) at one go in the current request. It will be formed as
-// query string for the request.
-//
-// For Example: `status=pending&status=approved&status=open` in the URL after `?` mark.
-// 		client.R().
-//			SetQueryParamsFromValues(url.Values{
-//				"status": []string{"pending", "approved", "open"},
-//			})
-// Also you can override query params value, which was set at client instance level.
-func (c *Client) SetQueryParamsFromValues(params url.Values) *Client {
-	for p, v := range params {
-		for _, pv := range v {
-			c.QueryParam.Add(p, pv)
+// prepareRequest builds the request from the request state, returning also the header
+// it was built with, before cookies were added, and the size of the encoded body.
+func (c *Client) prepareRequest(method, url string) (*http.Request, http.Header, int64, error) {
+	url = applyPathParams(c.resolveURL(url), c.PathParams)
+	if c.RawQuery != "" {
+		url = fmt.Sprintf("%s?%s", url, c.RawQuery)
+	} else if len(c.QueryParam) > 0 {
+		url = fmt.Sprintf("%s?%s", url, c.encodeQuery())
+	}
+
+	reader, contentType, err := c.requestBody()
+	if err != nil {
+		return nil, nil, 0, &MarshalError{err}
+	}
+	if sized, ok := reader.(interface{ Len() int }); ok && c.maxBodySize > 0 && int64(sized.Len()) > c.maxBodySize {
+		err = fmt.Errorf("request body of %d bytes exceeds max body size of %d bytes", sized.Len(), c.maxBodySize)
+		return nil, nil, 0, &MarshalError{err}
+	}
+	header := c.defaultHeader.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	for k, v := range c.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	if contentType != "" {
+		header.Set(headerContentType, contentType)
+	}
+	if c.userAgent != "" && header.Get(headerUserAgent) == "" {
+		header.Set(headerUserAgent, c.userAgent)
+	}
+	if len(c.acceptEncoding) > 0 && header.Get(headerAcceptEncoding) == "" {
+		header.Set(headerAcceptEncoding, strings.Join(c.acceptEncoding, ", "))
+	}
+	if c.compress && reader != nil {
+		if reader, err = gzipBody(reader); err != nil {
+			return nil, nil, 0, &MarshalError{err}
 		}
+		header.Set(headerContentEncoding, "gzip")
 	}
-	return c
+
+	request, err := c.newRequest(method, url, reader, header)
+	if err != nil {
+		return nil, nil, 0, &TransportError{err}
+	}
+	// known for the encoded bodies, streamed BodyReader is unknown
+	size := request.ContentLength
+	if c.setContentLength {
+		request.ContentLength = c.contentLength
+	}
+	if c.chunked {
+		request.TransferEncoding = []string{"chunked"}
+		request.ContentLength = -1
+	}
+	return request, header, size, nil
 }
 
-// SetQueryString method provides ability to use string as an input to set URL query string for the request.
-//
-// Using String as an input
-// 		client.R().
-//			SetQueryString("productId=232&template=fresh-sample&cat=resty&source=google&kw=buy a lot more")
-func (c *Client) SetQueryString(query string) *Client {
-	params, err := url.ParseQuery(strings.TrimSpace(query))
-	if err == nil {
-		for p, v := range params {
-			for _, pv := range v {
-				c.QueryParam.Add(p, pv)
+func (c *Client) newRequest(method, url string, body io.Reader, header http.Header) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(c.ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	// copy the header so the handler and hooks can't change the client
+	request.Header = header.Clone()
+
+	request.RemoteAddr = c.remoteAddr
+	if c.host != "" {
+		request.Host = c.host
+	}
+	if c.tls {
+		request.URL.Scheme = "https"
+		if request.URL.Host == "" {
+			request.URL.Host = request.Host
+		}
+		if request.URL.Host == "" {
+			// the same default as httptest.NewRequest
+			request.URL.Host = "example.com"
+		}
+		request.TLS = &tls.ConnectionState{
+			Version:           tls.VersionTLS12,
+			HandshakeComplete: true,
+			ServerName:        request.Host,
+		}
+	}
+
+	for _, cookie := range c.Cookies {
+		request.AddCookie(cookie)
+	}
+	if c.jar != nil {
+		for _, cookie := range c.jar.Cookies(cookieURL(request)) {
+			request.AddCookie(cookie)
+		}
+	}
+	return request, nil
+}
+
+// served is the outcome of serve.
+type served struct {
+	result   *http.Response
+	chunks   [][]byte
+	bodyRead int64
+	err      error
+}
+
+// serve runs the request hooks and the request through the handler, closing a streamed request body afterwards.
+// The outcome has also the flushed chunks, when the recorder has them, the number of body bytes
+// the handler read, and the timeout error along with a 504 response, when the handler runs
+// longer than SetTimeout.
+func (c *Client) serve(request *http.Request) served {
+	for _, fn := range c.beforeRequest {
+		fn(request)
+	}
+	if c.signer != nil {
+		body, err := replayBody(request)
+		if err != nil {
+			return served{result: httptest.NewRecorder().Result(), err: &TransportError{err}}
+		}
+		c.signer(request, body)
+	}
+
+	counter := &countingReader{ReadCloser: request.Body}
+	if request.Body != nil && request.Body != http.NoBody {
+		request.Body = counter
+	}
+
+	var recorder http.ResponseWriter = httptest.NewRecorder()
+	if c.newRecorder != nil {
+		recorder = c.newRecorder()
+	}
+	if c.timeout > 0 {
+		if !c.serveWithTimeout(recorder, request) {
+			if closer, ok := c.BodyReader.(io.Closer); ok {
+				closer.Close()
 			}
+			err := fmt.Errorf("handler timed out after %s", c.timeout)
+			timeout := httptest.NewRecorder()
+			timeout.WriteHeader(http.StatusGatewayTimeout)
+			io.WriteString(timeout, err.Error())
+			return served{result: timeout.Result(), bodyRead: counter.Count(), err: &TransportError{err}}
 		}
 	} else {
-		//c.client.log.Errorf("%v", err)
+		c.handle(recorder, request)
+	}
+	if closer, ok := c.BodyReader.(io.Closer); ok {
+		closer.Close()
 	}
-	return c
-}
 
-// SetResult ...
-func (c *Client) SetResult(result interface{}) *Client {
-	c.Result = result
-	return c
+	resulter, ok := recorder.(interface{ Result() *http.Response })
+	if !ok {
+		panic(fmt.Sprintf("testy: recorder %T has no Result method", recorder))
+	}
+	result := resulter.Result()
+	if c.jar != nil {
+		c.jar.SetCookies(cookieURL(request), result.Cookies())
+	}
+
+	var chunks [][]byte
+	if chunker, ok := recorder.(interface{ Chunks() [][]byte }); ok {
+		chunks = chunker.Chunks()
+	}
+	return served{result: result, chunks: chunks, bodyRead: counter.Count()}
 }
 
-// SetBody method sets the request body for the request. Similar to resty.
-// We can say its quite handy or powerful. Supported request body data types is `string`,
-// `[]byte`, `struct`, `map` and `slice` (not io.Reader currently).
-// Automatic marshalling for JSON (not XML), if it is `struct`, `map`, or `slice`.
-func (c *Client) SetBody(body interface{}) *Client {
+// handle serves the request by the handler for it, after the artificial latency.
+func (c *Client) handle(w http.ResponseWriter, request *http.Request) {
+	if c.latency > 0 {
+		time.Sleep(c.latency)
+	}
+	c.handlerFor(request).ServeHTTP(w, request)
+}
 
-	var bodyBytes []byte
-	//contentType := r.Header.Get("Content-Type")
-	kind := kindOf(body)
+// handlerFor returns the mounted handler with the longest prefix matching the request path,
+// or the client handler. The root prefix, trimmed to "", matches any path.
+func (c *Client) handlerFor(request *http.Request) http.Handler {
+	handler, matched := c.handler, -1
+	for _, m := range c.mounts {
+		path := request.URL.Path
+		if len(m.prefix) > matched && (path == m.prefix || strings.HasPrefix(path, m.prefix+"/")) {
+			handler, matched = m.handler, len(m.prefix)
+		}
+	}
+	return handler
+}
 
-	if b, ok := body.([]byte); ok {
-		bodyBytes = b
-	} else if s, ok := body.(string); ok {
-		bodyBytes = []byte(s)
-	} else if kind == reflect.Struct || kind == reflect.Map || kind == reflect.Slice {
-		var err error
-		bodyBytes, err = json.Marshal(body)
+// replayBody returns the request body bytes, leaving the body readable for the handler.
+// A streamed body is read into memory.
+func replayBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, nil
+	}
+	if request.GetBody != nil {
+		body, err := request.GetBody()
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
+		defer body.Close()
+		return ioutil.ReadAll(body)
 	}
-
-	if bodyBytes == nil {
-		panic("unsupported 'Body' type/value")
+	data, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return nil, err
 	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
 
-	c.Body = bodyBytes
-	return c
+// countingReader counts the bytes read from the request body by the handler.
+type countingReader struct {
+	io.ReadCloser
+	n int64
 }
 
-func (r *Response) String() string {
-	return string(r.Body)
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(&r.n, int64(n))
+	return n, err
 }
 
-// Borrowed from resty/utils.go
-func typeOf(i interface{}) reflect.Type {
-	return indirect(valueOf(i)).Type()
+// Count returns the number of bytes read so far.
+func (r *countingReader) Count() int64 {
+	return atomic.LoadInt64(&r.n)
 }
 
-func valueOf(i interface{}) reflect.Value {
-	return reflect.ValueOf(i)
+// serveWithTimeout runs the handler in the background with the request context cancelled
+// after the timeout, reporting whether it returned in time. The handler panic is passed on.
+func (c *Client) serveWithTimeout(w http.ResponseWriter, request *http.Request) bool {
+	ctx, cancel := context.WithTimeout(request.Context(), c.timeout)
+	defer cancel()
+
+	done := make(chan interface{}, 1)
+	go func() {
+		defer func() { done <- recover() }()
+		c.handle(w, request.WithContext(ctx))
+	}()
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case p := <-done:
+		if p != nil {
+			panic(p)
+		}
+		return true
+	case <-timer.C:
+		return false
+	}
 }
 
-func indirect(v reflect.Value) reflect.Value {
-	return reflect.Indirect(v)
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
 }
 
-func kindOf(v interface{}) reflect.Kind {
-	return typeOf(v).Kind()
+// redirectRequest builds the request following the redirect result, the same way http.Client does:
+// 301, 302 and 303 switch to GET without body, while 307 and 308 preserve method and body.
+// Returns nil when the redirect can't be followed.
+func (c *Client) redirectRequest(prev *http.Request, result *http.Response, header http.Header) *http.Request {
+	location := result.Header.Get("Location")
+	if location == "" {
+		return nil
+	}
+	u, err := prev.URL.Parse(location)
+	if err != nil {
+		return nil
+	}
+
+	method := prev.Method
+	var body io.Reader
+	switch result.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther:
+		if method != MethodGet && method != MethodHead {
+			method = MethodGet
+			header = header.Clone()
+			header.Del(headerContentType)
+		}
+	default:
+		if prev.GetBody != nil {
+			if body, err = prev.GetBody(); err != nil {
+				return nil
+			}
+		} else if prev.Body != nil && prev.Body != http.NoBody {
+			// streamed body can't be replayed
+			return nil
+		}
+	}
+	request, err := c.newRequest(method, u.String(), body, header)
+	if err != nil {
+		return nil
+	}
+	return request
+}
+
+// encodeQuery encodes the query params sorted by key like url.Values.Encode, except that
+// the values of params set by SetQueryParamNoEscape are written verbatim.
+func (c *Client) encodeQuery() string {
+	if len(c.noEscape) == 0 {
+		return c.QueryParam.Encode()
+	}
+	keys := make([]string, 0, len(c.QueryParam))
+	for k := range c.QueryParam {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf strings.Builder
+	for _, k := range keys {
+		for _, v := range c.QueryParam[k] {
+			if buf.Len() > 0 {
+				buf.WriteByte('&')
+			}
+			buf.WriteString(url.QueryEscape(k))
+			buf.WriteByte('=')
+			if c.noEscape[k] {
+				buf.WriteString(v)
+			} else {
+				buf.WriteString(url.QueryEscape(v))
+			}
+		}
+	}
+	return buf.String()
+}
+
+// resolveURL prepends the base URL to the url, unless it's absolute.
+func (c *Client) resolveURL(rawURL string) string {
+	if c.baseURL == "" {
+		return rawURL
+	}
+	if u, err := url.Parse(rawURL); err == nil && u.IsAbs() {
+		return rawURL
+	}
+	if rawURL == "" {
+		return c.baseURL
+	}
+	return strings.TrimSuffix(c.baseURL, "/") + "/" + strings.TrimPrefix(rawURL, "/")
+}
+
+func applyPathParams(rawURL string, params map[string]string) string {
+	for p, v := range params {
+		rawURL = strings.Replace(rawURL, "{"+p+"}", url.PathEscape(v), -1)
+	}
+	return rawURL
+}
+
+func (c *Client) decodeJSON(body []byte, v interface{}) error {
+	if c.jsonUnmarshal != nil {
+		return c.jsonUnmarshal(body, v)
+	}
+	if !c.disallowUnknownFields && !c.useNumber {
+		return json.Unmarshal(body, v)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if c.disallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if c.useNumber {
+		decoder.UseNumber()
+	}
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	// reject trailing data, like json.Unmarshal
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("json: unexpected data after top-level value")
+	}
+	return nil
+}
+
+// cookieURL resolves the request URL against the request Host, or the default httptest host,
+// since the cookie jar ignores URLs without scheme and host.
+func cookieURL(request *http.Request) *url.URL {
+	u := *request.URL
+	if u.Scheme == "" {
+		u.Scheme = "http"
+	}
+	if u.Host == "" {
+		u.Host = request.Host
+	}
+	if u.Host == "" {
+		u.Host = "example.com"
+	}
+	return &u
+}
+
+// requestBody returns the body to send and, when it has to be set, the matching Content-Type.
+// Files are always sent as multipart/form-data along with FormData fields, otherwise
+// FormData is only encoded when there's no explicit Body, or when no Content-Type was given.
+func (c *Client) requestBody() (io.Reader, string, error) {
+	hasBody := c.Body != nil || c.BodyReader != nil
+
+	if len(c.Files) > 0 {
+		if hasBody {
+			return nil, "", errors.New("ambiguous request body: both 'Body' and 'Files' are set")
+		}
+		body, contentType, err := c.multipartBody()
+		if err != nil {
+			return nil, "", err
+		}
+		return bytes.NewReader(body), contentType, nil
+	}
+
+	if len(c.FormData) == 0 {
+		if c.BodyReader != nil {
+			return c.BodyReader, "", nil
+		}
+		if c.Body != nil {
+			return bytes.NewReader(c.Body), "", nil
+		}
+		return nil, "", nil
+	}
+
+	explicitType := c.contentType()
+	if hasBody && explicitType != "" {
+		return nil, "", errors.New("ambiguous request body: both 'Body' and 'FormData' are set with explicit Content-Type")
+	}
+
+	body := strings.NewReader(c.FormData.Encode())
+	if !hasBody && explicitType != "" {
+		return body, "", nil
+	}
+	return body, formContentType, nil
+}
+
+// contentType returns the Content-Type of the current request, falling back to the default header.
+func (c *Client) contentType() string {
+	if contentType := c.Header.Get(headerContentType); contentType != "" {
+		return contentType
+	}
+	return c.defaultHeader.Get(headerContentType)
+}
+
+func gzipBody(body io.Reader) (io.Reader, error) {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := io.Copy(w, body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func gunzip(body []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func (c *Client) multipartBody() ([]byte, string, error) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	if c.Boundary != "" {
+		if err := w.SetBoundary(c.Boundary); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// fields are written with keys sorted, like url.Values.Encode, so the body is deterministic
+	keys := make([]string, 0, len(c.FormData))
+	for k := range c.FormData {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, kv := range c.FormData[k] {
+			if err := w.WriteField(k, kv); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	for _, f := range c.Files {
+		part, err := w.CreateFormFile(f.ParamName, f.Name)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err = io.Copy(part, f.Reader); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+// Clone method copies the client, so the copy has its own query and path params, form data,
+// headers and body, while sharing the handler. Body readers, hooks and cookie jar are shared too.
+// The history isn't copied, the copy records its own.
+// It's handy to configure a base client with common headers, and clone it for each test.
+//
+// 		base := testy.New(handler).SetAuthToken(token)
+// 		response := base.Clone().SetQueryParam("page", "2").Get("/items")
+func (c *Client) Clone() *Client {
+	cc := *c
+	cc.QueryParam = copyValues(c.QueryParam)
+	cc.FormData = copyValues(c.FormData)
+	cc.noEscape = make(map[string]bool, len(c.noEscape))
+	for k, v := range c.noEscape {
+		cc.noEscape[k] = v
+	}
+	cc.Header = c.Header.Clone()
+	cc.defaultHeader = c.defaultHeader.Clone()
+	cc.PathParams = make(map[string]string, len(c.PathParams))
+	for k, v := range c.PathParams {
+		cc.PathParams[k] = v
+	}
+	if c.Body != nil {
+		cc.Body = append([]byte{}, c.Body...)
+	}
+	cc.Cookies = append([]*http.Cookie(nil), c.Cookies...)
+	cc.Files = append([]*File(nil), c.Files...)
+	cc.mounts = append([]mount(nil), c.mounts...)
+	cc.retryOn = append([]int(nil), c.retryOn...)
+	cc.beforeRequest = append(([]func(*http.Request))(nil), c.beforeRequest...)
+	cc.afterResponse = append(([]func(*Response))(nil), c.afterResponse...)
+	cc.history = nil
+	return &cc
+}
+
+func copyValues(values url.Values) url.Values {
+	cv := make(url.Values, len(values))
+	for k, v := range values {
+		cv[k] = append([]string(nil), v...)
+	}
+	return cv
+}
+
+// Reset method clears the request state: query and path params, form data, headers, cookies, body and its length, files,
+// context, Result, Error and LastError, so the client can be reused for an unrelated request.
+// Cookie jar and default headers are retained.
+//
+// 		client.SetQueryParam("page", "1").Get("/items")
+// 		client.Reset().Get("/items") // no page param
+func (c *Client) Reset() *Client {
+	c.QueryParam = url.Values{}
+	c.RawQuery = ""
+	c.noEscape = nil
+	c.PathParams = map[string]string{}
+	c.FormData = url.Values{}
+	c.Header = http.Header{}
+	c.Cookies = nil
+	c.Body = nil
+	c.BodyReader = nil
+	c.Files = nil
+	c.Boundary = ""
+	c.setContentLength = false
+	c.chunked = false
+	c.Result = nil
+	c.Error = nil
+	c.ctx = context.Background()
+	c.lastErr = nil
+	return c
+}
+
+// SetHeader method is to set a single header field and its value in the current request.
+//
+// For Example: To set `Content-Type` and `Accept` as `application/json`.
+// 		client.SetHeader("Content-Type", "application/json").
+// 			SetHeader("Accept", "application/json")
+func (c *Client) SetHeader(header, value string) *Client {
+	c.Header.Set(header, value)
+	return c
+}
+
+// AddHeader method adds a value to the header field in the current request, keeping
+// the values already set, so repeated values are sent in order.
+//
+// 		client.AddHeader("Accept", "application/json").
+// 			AddHeader("Accept", "text/plain")
+func (c *Client) AddHeader(header, value string) *Client {
+	c.Header.Add(header, value)
+	return c
+}
+
+// SetDefaultHeader method sets a header field sent with every request of the client, and its clones.
+// Unlike SetHeader, it's not cleared by Reset, while SetHeader overrides it for the current request.
+//
+// 		base := testy.New(handler).SetDefaultHeader("Accept", "application/json")
+func (c *Client) SetDefaultHeader(header, value string) *Client {
+	if c.defaultHeader == nil {
+		c.defaultHeader = http.Header{}
+	}
+	c.defaultHeader.Set(header, value)
+	return c
+}
+
+// SetUserAgent method sets the User-Agent header sent with every request, "testy/1.0" by default.
+// Empty string disables it, a User-Agent set by SetHeader takes precedence.
+//
+// 		client.SetUserAgent("Mozilla/5.0")
+func (c *Client) SetUserAgent(ua string) *Client {
+	c.userAgent = ua
+	return c
+}
+
+// SetIfModifiedSince method sets the If-Modified-Since header to the time, formatted as HTTP date.
+//
+// 		client.SetIfModifiedSince(lastModified).Get("/report")
+func (c *Client) SetIfModifiedSince(t time.Time) *Client {
+	return c.SetHeader("If-Modified-Since", t.UTC().Format(http.TimeFormat))
+}
+
+// SetIfNoneMatch method sets the If-None-Match header to the entity tag, which is quoted
+// unless it already is, or it's a weak tag or `*`.
+//
+// 		client.SetIfNoneMatch("v42").Get("/report") // If-None-Match: "v42"
+func (c *Client) SetIfNoneMatch(etag string) *Client {
+	if etag != "*" && !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, "W/") {
+		etag = strconv.Quote(etag)
+	}
+	return c.SetHeader("If-None-Match", etag)
+}
+
+// SetRange method sets the Range header for the bytes from start to end, inclusive.
+// Negative end requests the rest of the content, i.e. `bytes=start-`.
+//
+// 		client.SetRange(0, 99).Get("/video") // Range: bytes=0-99
+func (c *Client) SetRange(start, end int64) *Client {
+	if end < 0 {
+		return c.SetHeader("Range", fmt.Sprintf("bytes=%d-", start))
+	}
+	return c.SetHeader("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+}
+
+// SetAcceptEncoding method sets the encodings sent in the Accept-Encoding header of every request,
+// gzip by default, like a real client. No encodings disable the header, an Accept-Encoding
+// set by SetHeader takes precedence. Gzip encoded responses are decoded transparently.
+//
+// 		client.SetAcceptEncoding("gzip", "br")
+// 		client.SetAcceptEncoding() // no Accept-Encoding
+func (c *Client) SetAcceptEncoding(encodings ...string) *Client {
+	c.acceptEncoding = encodings
+	return c
+}
+
+// SetHeaders method sets multiple headers field and its values at one go in the current request.
+//
+// For Example: To set `Content-Type` and `Accept` as `application/json`
+//
+// 		client.SetHeaders(map[string]string{
+// 			"Content-Type": "application/json",
+// 			"Accept": "application/json",
+// 		})
+func (c *Client) SetHeaders(headers map[string]string) *Client {
+	for h, v := range headers {
+		c.SetHeader(h, v)
+	}
+	return c
+}
+
+// SetBasicAuth method sets the basic authentication header in the current request.
+//
+// For Example: Authorization: Basic <base64-encoded-value>
+// 		client.SetBasicAuth("myuser", "mypass")
+func (c *Client) SetBasicAuth(username, password string) *Client {
+	auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return c.SetHeader(headerAuthorization, "Basic "+auth)
+}
+
+// SetAuthToken method sets bearer auth token header in the current request.
+//
+// For Example: Authorization: Bearer <auth token value comes here>
+// 		client.SetAuthToken("BC594900518B4F7EAC75BD37F019E08FBC594900518B4F7EAC75BD37F019E08F")
+func (c *Client) SetAuthToken(token string) *Client {
+	return c.SetHeader(headerAuthorization, "Bearer "+token)
+}
+
+// SetQueryParam method sets single parameter and its value in the current request.
+// It will be formed as query string for the request.
+//
+// For Example: `search=kitchen%20papers&size=large` in the URL after `?` mark.
+// 		client.SetQueryParam("search", "kitchen papers").
+// 			SetQueryParam("size", "large")
+func (c *Client) SetQueryParam(param, value string) *Client {
+	c.QueryParam.Set(param, value)
+	return c
+}
+
+// SetQueryParamNoEscape method sets single parameter and its value in the current request,
+// like SetQueryParam, but the value is not percent-encoded in the query string,
+// e.g. for an endpoint expecting literal commas.
+//
+// For Example: `status=a,b,c` in the URL after `?` mark.
+// 		client.SetQueryParamNoEscape("status", "a,b,c")
+func (c *Client) SetQueryParamNoEscape(param, value string) *Client {
+	c.QueryParam.Set(param, value)
+	if c.noEscape == nil {
+		c.noEscape = map[string]bool{}
+	}
+	c.noEscape[param] = true
+	return c
+}
+
+// SetQueryParams method sets multiple parameters and its values at one go in the current request.
+// It will be formed as query string for the request.
+//
+// For Example: `search=kitchen%20papers&size=large` in the URL after `?` mark.
+// 		client.SetQueryParams(map[string]string{
+// 			"search": "kitchen papers",
+// 			"size": "large",
+// 		})
+func (c *Client) SetQueryParams(params map[string]string) *Client {
+	for p, v := range params {
+		c.SetQueryParam(p, v)
+	}
+	return c
+}
+
+// SetQueryParamsFromValues method appends multiple parameters with multi-value
+// (`url.Values`) at one go in the current request. It will be formed as
+// query string for the request.
+//
+// For Example: `status=pending&status=approved&status=open` in the URL after `?` mark.
+// 		client.SetQueryParamsFromValues(url.Values{
+// 			"status": []string{"pending", "approved", "open"},
+// 		})
+func (c *Client) SetQueryParamsFromValues(params url.Values) *Client {
+	for p, v := range params {
+		for _, pv := range v {
+			c.QueryParam.Add(p, pv)
+		}
+	}
+	return c
+}
+
+// SetRawQuery method sets the URL query string for the request verbatim, without parsing
+// or encoding, e.g. to reproduce a malformed client. It overrides the query params when both are set.
+//
+// 		client.SetRawQuery("b=2&a=1&b=3")
+func (c *Client) SetRawQuery(raw string) *Client {
+	c.RawQuery = raw
+	return c
+}
+
+// SetQueryParamsFromStruct method adds the exported fields of a struct as query parameters
+// in the current request. The parameter name is taken from the `url` tag, or the field name,
+// slices are added as repeated parameters, and zero values are skipped with `omitempty` option.
+// Fields with `url:"-"` tag are skipped, as are nil pointers, for optional values,
+// while other pointers are dereferenced.
+//
+// 		type Filter struct {
+// 			Search string   `url:"search"`
+// 			Page   *int     `url:"page,omitempty"`
+// 			Status []string `url:"status"`
+// 		}
+// 		client.SetQueryParamsFromStruct(Filter{Search: "kitchen papers", Status: []string{"pending", "open"}})
+func (c *Client) SetQueryParamsFromStruct(v interface{}) *Client {
+	if c.t != nil {
+		defer c.recoverT("SetQueryParamsFromStruct")
+	}
+	value := indirect(valueOf(v))
+	if value.Kind() != reflect.Struct {
+		panic(&MarshalError{errors.New("unsupported 'QueryParams' type/value")})
+	}
+
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, opts := field.Name, ""
+		if tag, ok := field.Tag.Lookup("url"); ok {
+			if tag == "-" {
+				continue
+			}
+			if idx := strings.Index(tag, ","); idx >= 0 {
+				tag, opts = tag[:idx], tag[idx+1:]
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+
+		fv := value.Field(i)
+		if opts == "omitempty" && isZero(fv) {
+			continue
+		}
+		if fv.Kind() == reflect.Ptr {
+			// optional value, set only when given
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array {
+			for j := 0; j < fv.Len(); j++ {
+				c.QueryParam.Add(name, fmt.Sprint(fv.Index(j).Interface()))
+			}
+			continue
+		}
+		c.QueryParam.Add(name, fmt.Sprint(fv.Interface()))
+	}
+	return c
+}
+
+// SetQueryString method provides ability to use string as an input to set URL query string for the request.
+// If the query string can't be parsed nothing is added, and the error is available from LastError.
+//
+// Using String as an input
+// 		client.SetQueryString("productId=232&template=fresh-sample&cat=resty&source=google&kw=buy a lot more")
+func (c *Client) SetQueryString(query string) *Client {
+	params, err := url.ParseQuery(strings.TrimSpace(query))
+	if err == nil {
+		for p, v := range params {
+			for _, pv := range v {
+				c.QueryParam.Add(p, pv)
+			}
+		}
+	} else {
+		c.lastErr = err
+	}
+	return c
+}
+
+// LastError method returns the last error recorded while setting up the request,
+// e.g. an invalid query string passed to SetQueryString, or nil.
+func (c *Client) LastError() error {
+	return c.lastErr
+}
+
+// SetPathParam method sets single URL path key-value pair in the current request.
+// The value is escaped and replaces the `{name}` placeholder in the URL.
+//
+// For Example: URL `/v1/users/{userId}/details` becomes `/v1/users/sample@sample.com/details`
+// 		client.SetPathParam("userId", "sample@sample.com").
+// 			Get("/v1/users/{userId}/details")
+func (c *Client) SetPathParam(name, value string) *Client {
+	c.PathParams[name] = value
+	return c
+}
+
+// SetPathParams method sets multiple URL path key-value pairs at one go in the current request.
+//
+// 		client.SetPathParams(map[string]string{
+// 			"userId": "sample@sample.com",
+// 			"subAccountId": "100002",
+// 		}).
+// 			Get("/v1/users/{userId}/{subAccountId}/details")
+func (c *Client) SetPathParams(params map[string]string) *Client {
+	for p, v := range params {
+		c.SetPathParam(p, v)
+	}
+	return c
+}
+
+// SetFormData method sets Form parameters and their values in the current request.
+// It's applicable only when no explicit Body is set, or when no Content-Type was given,
+// and is sent as `application/x-www-form-urlencoded`.
+//
+// 		client.SetFormData(map[string]string{
+// 			"access_token": "BC594900-518B-4F7E-AC75-BD37F019E08F",
+// 			"user_id": "3455454545",
+// 		})
+func (c *Client) SetFormData(data map[string]string) *Client {
+	for k, v := range data {
+		c.FormData.Set(k, v)
+	}
+	return c
+}
+
+// SetFormDataFromValues method appends multiple form parameters with multi-value
+// (`url.Values`) at one go in the current request.
+//
+// 		client.SetFormDataFromValues(url.Values{
+// 			"search_criteria": []string{"book", "glass", "pencil"},
+// 		})
+func (c *Client) SetFormDataFromValues(data url.Values) *Client {
+	for k, v := range data {
+		for _, kv := range v {
+			c.FormData.Add(k, kv)
+		}
+	}
+	return c
+}
+
+// AddFormData method appends the value to the form parameter, e.g. for repeated keys.
+// Values of a key are sent in the order they were added, keys are sorted.
+//
+// 		client.AddFormData("tag", "go").
+// 			AddFormData("tag", "http")
+func (c *Client) AddFormData(key, value string) *Client {
+	c.FormData.Add(key, value)
+	return c
+}
+
+// SetFile method is to set single file field name and its path for multipart upload.
+//
+// 		client.SetFile("my_file", "/tmp/report.pdf")
+func (c *Client) SetFile(param, filePath string) *Client {
+	if c.t != nil {
+		defer c.recoverT("SetFile")
+	}
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		panic(&MarshalError{err})
+	}
+	return c.SetFileReader(param, filepath.Base(filePath), bytes.NewReader(data))
+}
+
+// SetFileReader method is to set single file using io.Reader for multipart upload.
+//
+// 		client.SetFileReader("profile_img", "my-profile-img.png", bytes.NewReader(profileImgBytes)).
+// 			SetFileReader("notes", "user-notes.txt", bytes.NewReader(notesBytes))
+// FormData fields are sent in the same multipart body.
+func (c *Client) SetFileReader(param, fileName string, reader io.Reader) *Client {
+	c.Files = append(c.Files, &File{
+		Name:      fileName,
+		ParamName: param,
+		Reader:    reader,
+	})
+	return c
+}
+
+// SetMultipartBoundary method sets the boundary used for multipart request body, which
+// is otherwise random. It's handy for asserting on the raw body in tests.
+func (c *Client) SetMultipartBoundary(boundary string) *Client {
+	c.Boundary = boundary
+	return c
+}
+
+// SetContext method sets the context.Context for the current request, allowing
+// handlers to observe cancellation and deadlines. Defaults to context.Background().
+//
+// 		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+// 		defer cancel()
+// 		client.SetContext(ctx).Get("/slow")
+func (c *Client) SetContext(ctx context.Context) *Client {
+	c.ctx = ctx
+	return c
+}
+
+// SetContextValue method adds the value to the context of the current request, e.g. a value
+// the handler expects from an upstream middleware. Values added before SetContext are discarded.
+//
+// 		client.SetContextValue(spanKey{}, span).Get("/traced")
+func (c *Client) SetContextValue(key, val interface{}) *Client {
+	c.ctx = context.WithValue(c.ctx, key, val)
+	return c
+}
+
+// Mount method routes the requests with the path prefix to the handler, instead of the client
+// handler, e.g. to mock several services with one client. The prefix matches whole path
+// segments, the longest matching prefix wins, and the path is passed to the handler as is.
+// Mounting on "/" replaces the client handler for the paths not matching other prefixes.
+//
+// 		client := testy.New(api).Mount("/auth", authService)
+// 		client.Get("/auth/token") // served by authService
+func (c *Client) Mount(prefix string, h http.Handler) *Client {
+	if h == nil {
+		panic("testy: handler is nil")
+	}
+	c.mounts = append(c.mounts, mount{prefix: strings.TrimSuffix(prefix, "/"), handler: h})
+	return c
+}
+
+// SetBaseURL method sets the prefix prepended to the url of each request, joined by a single slash.
+// Absolute URLs, e.g. http://example.com/users, are used as is.
+//
+// 		client := testy.New(handler).SetBaseURL("/api/v1")
+// 		client.Get("/users") // GET /api/v1/users
+func (c *Client) SetBaseURL(prefix string) *Client {
+	c.baseURL = prefix
+	return c
+}
+
+// SetTLS method makes the request appear to be received over TLS, with non-nil
+// request TLS connection state and https URL scheme, e.g. to test secure cookies or HSTS.
+//
+// 		client.SetTLS(true).Get("/login")
+func (c *Client) SetTLS(enabled bool) *Client {
+	c.tls = enabled
+	return c
+}
+
+// SetRemoteAddr method sets the request RemoteAddr, which is empty by default,
+// e.g. to test IP based access rules.
+//
+// 		client.SetRemoteAddr("10.0.0.1:1234")
+func (c *Client) SetRemoteAddr(addr string) *Client {
+	c.remoteAddr = addr
+	return c
+}
+
+// SetHost method sets the request Host, e.g. to test virtual host routing,
+// since Host header set by SetHeader is ignored.
+//
+// 		client.SetHost("api.example.com")
+func (c *Client) SetHost(host string) *Client {
+	c.host = host
+	return c
+}
+
+// SetCookie method appends a cookie to the current request, e.g. a session cookie
+// for a protected endpoint without going through the login with EnableCookieJar.
+//
+// 		client.SetCookie(&http.Cookie{Name: "session", Value: "7f3c2a"})
+func (c *Client) SetCookie(cookie *http.Cookie) *Client {
+	c.Cookies = append(c.Cookies, cookie)
+	return c
+}
+
+// SetCookies method appends multiple cookies to the current request.
+func (c *Client) SetCookies(cookies []*http.Cookie) *Client {
+	c.Cookies = append(c.Cookies, cookies...)
+	return c
+}
+
+// EnableCookieJar method enables cookie persistence across requests. Cookies set by
+// a response are sent back on subsequent requests matching their path and domain.
+//
+// 		client.EnableCookieJar()
+// 		client.Post("/login")
+// 		client.Get("/profile") // sends the session cookie set by /login
+func (c *Client) EnableCookieJar() *Client {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		panic(err)
+	}
+	c.jar = jar
+	return c
+}
+
+// SetContentLength method sets the request ContentLength explicitly, which is otherwise
+// inferred from the body, e.g. to test handlers relying on the declared length.
+//
+// 		client.SetBody(reader).SetContentLength(1024)
+func (c *Client) SetContentLength(n int64) *Client {
+	c.contentLength = n
+	c.setContentLength = true
+	return c
+}
+
+// SetChunked method sends the request body with chunked transfer encoding, i.e.
+// TransferEncoding `chunked` and unknown ContentLength of -1, e.g. to test handlers
+// of chunked uploads. It takes precedence over SetContentLength.
+//
+// 		client.SetBody(payload).SetChunked(true).Post("/upload")
+func (c *Client) SetChunked(chunked bool) *Client {
+	c.chunked = chunked
+	return c
+}
+
+// SetMaxBodySize method sets the limit of request body size, to catch accidentally huge
+// payloads. Execute panics when the body, including encoded form data and files, exceeds it.
+// An io.Reader body is checked when it reports its length with a Len() int method, as
+// *bytes.Reader, *bytes.Buffer and *strings.Reader do, other readers are streamed unchecked.
+// It's unlimited by default.
+//
+// 		client.SetMaxBodySize(1 << 20)
+func (c *Client) SetMaxBodySize(n int64) *Client {
+	c.maxBodySize = n
+	return c
+}
+
+// SetMaxResponseSize method sets the limit of response body bytes read, to guard against
+// misbehaving handlers. Longer bodies are truncated to the limit, with Response.Truncated set.
+// It's unlimited by default.
+//
+// 		client.SetMaxResponseSize(1 << 20)
+func (c *Client) SetMaxResponseSize(n int64) *Client {
+	c.maxResponseSize = n
+	return c
+}
+
+// SetFollowRedirects method enables following redirect responses (301, 302, 303, 307, 308)
+// up to the redirect limit, which is 10 by default. It's disabled by default.
+// Followed locations are recorded in Response.Redirects.
+//
+// 		client.SetFollowRedirects(true).Get("/old-page")
+func (c *Client) SetFollowRedirects(follow bool) *Client {
+	c.followRedirects = follow
+	return c
+}
+
+// SetMaxRedirects method sets the number of redirects followed before giving up,
+// in which case the last redirect response is returned with Response.Err set.
+func (c *Client) SetMaxRedirects(max int) *Client {
+	c.maxRedirects = max
+	return c
+}
+
+// SetCompression method enables gzip compression of the request body,
+// which is sent with `Content-Encoding: gzip` header.
+//
+// 		client.SetCompression(true).SetBody(largePayload).Post("/bulk")
+func (c *Client) SetCompression(enabled bool) *Client {
+	c.compress = enabled
+	return c
+}
+
+// SetRetry method enables retrying the request up to count times, while the response
+// status code is one of retryOn, or any 5xx status code when none are given.
+// The request body is sent in full on each attempt: streamed body and files are read
+// into memory before the first one.
+//
+// 		client.SetRetry(3, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+func (c *Client) SetRetry(count int, retryOn ...int) *Client {
+	c.retryCount = count
+	c.retryOn = retryOn
+	return c
+}
+
+// SetTimeout method limits how long the handler can run. The handler runs in the background,
+// with the request context cancelled after the timeout, and when it doesn't return in time,
+// the response is 504 Gateway Timeout, with *TransportError in Response.Err.
+//
+// 		response := client.SetTimeout(100 * time.Millisecond).Get("/slow")
+// 		assert.Equal(t, http.StatusGatewayTimeout, response.StatusCode)
+func (c *Client) SetTimeout(d time.Duration) *Client {
+	c.timeout = d
+	return c
+}
+
+// SetArtificialLatency method delays running the handler, making it appear slow, e.g. to exercise
+// timeout and retry paths deterministically. The latency counts towards SetTimeout.
+//
+// 		client.SetArtificialLatency(200 * time.Millisecond).SetTimeout(100 * time.Millisecond)
+func (c *Client) SetArtificialLatency(d time.Duration) *Client {
+	c.latency = d
+	return c
+}
+
+// SetFailBeforeHandler method makes requests fail with *TransportError wrapping err, without
+// calling the handler, e.g. to simulate a dropped connection. ExecuteE returns the error
+// and no response, Execute panics with it. Nil error restores normal requests.
+//
+// 		_, err := client.SetFailBeforeHandler(io.ErrUnexpectedEOF).ExecuteE(testy.MethodGet, "/hello")
+func (c *Client) SetFailBeforeHandler(err error) *Client {
+	c.failBeforeHandler = err
+	return c
+}
+
+// SetRecorderFactory method sets the function creating the http.ResponseWriter for each request,
+// httptest.NewRecorder by default, e.g. to inspect flushes. The writer must have
+// a Result() *http.Response method, which it gets by embedding *httptest.ResponseRecorder.
+//
+// 		type flushCounter struct {
+// 			*httptest.ResponseRecorder
+// 			flushes int
+// 		}
+//
+// 		client.SetRecorderFactory(func() http.ResponseWriter {
+// 			return &flushCounter{ResponseRecorder: httptest.NewRecorder()}
+// 		})
+func (c *Client) SetRecorderFactory(fn func() http.ResponseWriter) *Client {
+	c.newRecorder = fn
+	return c
+}
+
+// EnableChunks method records the response body in chunks, as the handler flushes it,
+// available from Response.Chunks, e.g. to test a handler streaming with http.Flusher.
+// A recorder set by SetRecorderFactory can provide them with a Chunks() [][]byte method.
+//
+// 		response := client.EnableChunks().Get("/events")
+// 		assert.Equal(t, [][]byte{[]byte("a"), []byte("b")}, response.Chunks)
+func (c *Client) EnableChunks() *Client {
+	return c.SetRecorderFactory(func() http.ResponseWriter {
+		return &chunkRecorder{ResponseRecorder: httptest.NewRecorder()}
+	})
+}
+
+// WithT method makes the client report its panics through t.Fatalf with the request as context,
+// instead of crashing the test, e.g. in table tests. It covers Execute and the method shortcuts,
+// ExecuteMethod, ExecuteConcurrent, SetBody, SetBodyXML, SetBodyNDJSON, SetFile and
+// SetQueryParamsFromStruct. ExecuteStream and Do still panic.
+//
+// 		client := testy.New(handler).WithT(t)
+// 		client.SetBody(func() {}) // fails the test: testy: SetBody: ...
+func (c *Client) WithT(t FatalT) *Client {
+	c.t = t
+	return c
+}
+
+func (c *Client) recoverT(context string) {
+	if p := recover(); p != nil {
+		c.t.Helper()
+		c.t.Fatalf("testy: %s: %v", context, p)
+	}
+}
+
+// OnBeforeRequest method registers a hook to modify the request right before it's
+// passed to the handler, e.g. for tracing headers. Hooks run in registration order,
+// and also for redirected and retried requests.
+//
+// 		client.OnBeforeRequest(func(r *http.Request) {
+// 			r.Header.Set("X-Trace-Id", "abc")
+// 		})
+func (c *Client) OnBeforeRequest(fn func(*http.Request)) *Client {
+	c.beforeRequest = append(c.beforeRequest, fn)
+	return c
+}
+
+// SetSigner method sets the function signing each request, e.g. with an HMAC header. It runs
+// after the OnBeforeRequest hooks, with the fully built request and its final body bytes,
+// as sent, i.e. compressed when SetCompression is enabled. Streamed bodies are read into memory.
+//
+// 		client.SetSigner(func(r *http.Request, body []byte) {
+// 			mac := hmac.New(sha256.New, key)
+// 			mac.Write(body)
+// 			r.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+// 		})
+func (c *Client) SetSigner(fn func(*http.Request, []byte)) *Client {
+	c.signer = fn
+	return c
+}
+
+// OnAfterResponse method registers a hook to inspect the response before it's returned
+// by Execute, e.g. for logging or common assertions. Hooks run in registration order.
+//
+// 		client.OnAfterResponse(func(r *testy.Response) {
+// 			log.Println(r.Status)
+// 		})
+func (c *Client) OnAfterResponse(fn func(*Response)) *Client {
+	c.afterResponse = append(c.afterResponse, fn)
+	return c
+}
+
+// EnableHistory method enables recording of every response returned by Execute,
+// e.g. to inspect all steps of a multi-request flow afterwards.
+//
+// 		client.EnableHistory()
+// 		client.Post("/login")
+// 		client.Get("/profile")
+// 		for _, response := range client.History() {
+// 			t.Log(response.Request.URL, response.Status)
+// 		}
+func (c *Client) EnableHistory() *Client {
+	c.recordHistory = true
+	return c
+}
+
+// History method returns the responses recorded since EnableHistory, in order.
+func (c *Client) History() []*Response {
+	return c.history
+}
+
+// SetResult method sets the value the JSON body of a successful, 2xx response is decoded into.
+// Other responses are left undecoded, unless SetResultOnAnyStatus is enabled.
+//
+// 		var user User
+// 		client.SetResult(&user).Get("/user")
+func (c *Client) SetResult(result interface{}) *Client {
+	c.Result = result
+	return c
+}
+
+// SetResultOnAnyStatus method decodes the body into Result regardless of the status code,
+// when it's not decoded into Error, as before Result was limited to 2xx responses.
+func (c *Client) SetResultOnAnyStatus(enabled bool) *Client {
+	c.resultOnAnyStatus = enabled
+	return c
+}
+
+// SetJSONDecoderOptions method configures decoding into Result and Error: disallowUnknown
+// fails on fields missing from the target struct, catching drift between the API and the struct,
+// and useNumber decodes numbers into interface{} as json.Number instead of float64.
+//
+// 		client.SetJSONDecoderOptions(true, false).SetResult(&User{})
+func (c *Client) SetJSONDecoderOptions(disallowUnknown, useNumber bool) *Client {
+	c.disallowUnknownFields = disallowUnknown
+	c.useNumber = useNumber
+	return c
+}
+
+// SetJSONMarshaler method sets the function used by SetBody to marshal JSON bodies,
+// instead of the encoding/json one, e.g. to use the same codec as production code.
+// It has to be set before SetBody.
+//
+// 		client.SetJSONMarshaler(jsoniter.Marshal)
+func (c *Client) SetJSONMarshaler(fn func(interface{}) ([]byte, error)) *Client {
+	c.jsonMarshal = fn
+	return c
+}
+
+// SetJSONUnmarshaler method sets the function used to unmarshal responses into Result
+// and Error, instead of the encoding/json one. It takes precedence over SetJSONDecoderOptions.
+//
+// 		client.SetJSONUnmarshaler(jsoniter.Unmarshal)
+func (c *Client) SetJSONUnmarshaler(fn func([]byte, interface{}) error) *Client {
+	c.jsonUnmarshal = fn
+	return c
+}
+
+// SetError method registers the object to unmarshal JSON error responses into,
+// when the response status code is 400 or above. Result is used otherwise.
+//
+// 		client.SetError(&ErrorResponse{}).
+// 			SetResult(&User{})
+func (c *Client) SetError(err interface{}) *Client {
+	c.Error = err
+	return c
+}
+
+// SetBody method sets the request body for the request. Similar to resty.
+// We can say its quite handy or powerful. Supported request body data types is `string`,
+// `[]byte`, `struct`, `map`, `slice` and `io.Reader`.
+// Automatic marshalling for JSON and XML, if it is `struct`, `map`, or `slice`, chosen by the
+// Content-Type header already set. Defaults to JSON, which also sets Content-Type to `application/json`.
+//
+// 		client.SetHeader("Content-Type", "application/xml").
+// 			SetBody(Note{To: "bob", Body: "hello"})
+// An `io.Reader` is streamed as is, and closed after the request if it's an `io.ReadCloser`.
+func (c *Client) SetBody(body interface{}) *Client {
+	if c.t != nil {
+		defer c.recoverT("SetBody")
+	}
+
+	if r, ok := body.(io.Reader); ok {
+		c.Body = nil
+		c.BodyReader = r
+		return c
+	}
+
+	var bodyBytes []byte
+	contentType := c.contentType()
+	kind := kindOf(body)
+
+	if b, ok := body.([]byte); ok {
+		bodyBytes = b
+	} else if s, ok := body.(string); ok {
+		bodyBytes = []byte(s)
+	} else if kind == reflect.Struct || kind == reflect.Map || kind == reflect.Slice {
+		var err error
+		if isXMLType(contentType) {
+			bodyBytes, err = xml.Marshal(body)
+		} else if c.jsonMarshal != nil {
+			bodyBytes, err = c.jsonMarshal(body)
+		} else {
+			bodyBytes, err = json.Marshal(body)
+		}
+		if err != nil {
+			panic(&MarshalError{err})
+		}
+		if contentType == "" {
+			c.Header.Set(headerContentType, jsonContentType)
+		}
+	}
+
+	if bodyBytes == nil {
+		panic(&MarshalError{errors.New("unsupported 'Body' type/value")})
+	}
+
+	c.Body = bodyBytes
+	c.BodyReader = nil
+	return c
+}
+
+// SetBodyXML method marshals the body as XML and sets it as the request body.
+// Content-Type is set to `application/xml`, unless it was already set.
+//
+// 		client.SetBodyXML(Note{To: "bob", Body: "hello"})
+func (c *Client) SetBodyXML(body interface{}) *Client {
+	if c.t != nil {
+		defer c.recoverT("SetBodyXML")
+	}
+	bodyBytes, err := xml.Marshal(body)
+	if err != nil {
+		panic(&MarshalError{err})
+	}
+	if c.contentType() == "" {
+		c.Header.Set(headerContentType, xmlContentType)
+	}
+	return c.SetBody(bodyBytes)
+}
+
+// SetBodyNDJSON method marshals each item as a line of JSON, terminated by a newline,
+// and sets them as the request body, e.g. for a bulk endpoint.
+// Content-Type is set to `application/x-ndjson`, unless it was already set.
+//
+// 		client.SetBodyNDJSON(User{Name: "alice"}, User{Name: "bob"})
+func (c *Client) SetBodyNDJSON(items ...interface{}) *Client {
+	if c.t != nil {
+		defer c.recoverT("SetBodyNDJSON")
+	}
+	var buf bytes.Buffer
+	for _, item := range items {
+		var line []byte
+		var err error
+		if c.jsonMarshal != nil {
+			line, err = c.jsonMarshal(item)
+		} else {
+			line, err = json.Marshal(item)
+		}
+		if err != nil {
+			panic(&MarshalError{err})
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if c.contentType() == "" {
+		c.Header.Set(headerContentType, ndjsonType)
+	}
+	return c.SetBody(buf.Bytes())
+}
+
+func (r *Response) String() string {
+	return string(r.Body)
+}
+
+// Borrowed from resty/utils.go
+func typeOf(i interface{}) reflect.Type {
+	return indirect(valueOf(i)).Type()
+}
+
+func valueOf(i interface{}) reflect.Value {
+	return reflect.ValueOf(i)
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	return reflect.Indirect(v)
+}
+
+func kindOf(v interface{}) reflect.Kind {
+	return typeOf(v).Kind()
+}
+
+func isXMLType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == xmlContentType || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
 }